@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/score"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// budgetsCmd groups the offline budget commands
+type budgetsCmd struct {
+	Test    budgetsTestCmd    `kong:"cmd,help='Evaluate a saved report against budgets with the same engine as the run command. No new report is created'"`
+	Suggest budgetsSuggestCmd `kong:"cmd,help='Suggest budgets from the distribution of the metrics in saved reports'"`
+
+	ImportLighthouse budgetsImportLighthouseCmd `kong:"cmd,help='Convert a Lighthouse budget.json into a local budgets file'"`
+	ExportLighthouse budgetsExportLighthouseCmd `kong:"cmd,help='Convert performance budgets and a local budgets file into a Lighthouse budget.json'"`
+}
+
+// budgetsTestCmd configures the budgets test command
+type budgetsTestCmd struct {
+	Report       string `kong:"required,type='existingfile',help='Saved report. Either a plain VitalFrog report json or an EXPORT_FILE'"`
+	Budgets      string `kong:"type='existingfile',help='Local budgets file. Replaces the local budgets saved in an EXPORT_FILE'"`
+	Preset       string `kong:"help='Built-in budgets to use instead of the performance budgets saved in an EXPORT_FILE (google-cwv-good|google-cwv-needs-improvement)'"`
+	Suppressions string `kong:"type='existingfile',help='Suppressions file. Replaces the suppressions saved in an EXPORT_FILE'"`
+	Baseline     string `kong:"type='existingfile',help='Saved report to compare the regressions of the budgets against'"`
+
+	ScoreWeighting string     `kong:"default='v10',enum='v6,v8,v10',help='Lighthouse version whose weights and curves the performance score is computed with (v6|v8|v10)'"`
+	CWVAssessment  bool       `kong:"default='true',negatable,help='Print the Core Web Vitals assessment per path and device below the table'"`
+	FailPolicy     failPolicy `kong:"embed"`
+
+	Markdown markdownSummary `kong:"embed"`
+	JUnit    string          `kong:"name='junit',help='Write the budget verdicts as JUnit XML to this file'"`
+	HTML     string          `kong:"name='html',help='Write a self-contained HTML report to this file'"`
+}
+
+// rules layers the budgets of the command over the ones saved in the export
+func (cmd budgetsTestCmd) rules(export *resultExport) (budget.Rules, error) {
+	performanceBudgets, localBudgets, suppressions := export.PerformanceBudgets, export.BudgetsConfig, export.Suppressions
+	if cmd.Preset != "" {
+		preset, ok := budget.Preset(cmd.Preset)
+		if !ok {
+			return budget.Rules{}, fmt.Errorf("unknown preset %q. Use one of %v", cmd.Preset, budget.PresetNames())
+		}
+		performanceBudgets = preset
+	}
+	if cmd.Budgets != "" {
+		var err error
+		localBudgets, err = budget.ReadConfig(cmd.Budgets)
+		if err != nil {
+			return budget.Rules{}, fmt.Errorf("could not ReadConfig: %w", err)
+		}
+	}
+	if cmd.Suppressions != "" {
+		var err error
+		suppressions, err = budget.ReadSuppressions(cmd.Suppressions)
+		if err != nil {
+			return budget.Rules{}, fmt.Errorf("could not ReadSuppressions: %w", err)
+		}
+	}
+
+	scoring, err := score.Lookup(cmd.ScoreWeighting)
+	if err != nil {
+		return budget.Rules{}, err
+	}
+	rules := budget.NewRules(performanceBudgets, localBudgets).
+		WithSuppressions(suppressions, time.Now()).
+		WithScoring(scoring)
+
+	switch {
+	case cmd.Baseline != "":
+		baseline, err := readResultExport(cmd.Baseline)
+		if err != nil {
+			return budget.Rules{}, fmt.Errorf("could not readResultExport: %w", err)
+		}
+		rules = rules.WithBaseline(budget.NewBaseline(baseline.Data))
+	case len(export.Baseline) > 0:
+		rules = rules.WithBaseline(budget.NewBaseline(export.Baseline))
+	}
+	return rules, nil
+}
+
+func runBudgetsTest(cmd budgetsTestCmd) {
+	if err := cmd.FailPolicy.check(); err != nil {
+		log.Fatalf("configCheck failed: %s", err)
+	}
+
+	export, err := readResultExport(cmd.Report)
+	if err != nil {
+		log.Fatalf("could not readResultExport: %s", err)
+	}
+	rules, err := cmd.rules(export)
+	if err != nil {
+		log.Fatalf("could not load budgets: %s", err)
+	}
+	if rules.Empty() && (rules.Local() == nil || len(rules.Local().Rules) == 0) {
+		log.Warnf("No budgets to test %q against. Use --budgets or --preset", cmd.Report)
+	}
+
+	//
+	// Print basic info
+	fmt.Print("\n----------\n")
+	fmt.Printf("\nTesting %d performance reports of %s offline\n", len(export.Data), cmd.Report)
+	if export.Metadata.Uuid != "" {
+		fmt.Printf("Report web url %s\n", reportWebUrl(export.Metadata.Uuid))
+	}
+	fmt.Print("\n----------\n")
+	printBudgetProblems(rules)
+
+	levels := writeReportTable(os.Stdout, rules, export.Data, export.Groups, cmd.CWVAssessment)
+	var written resultFiles
+	if cmd.JUnit != "" {
+		written.check(writeJUnit(cmd.JUnit, rules, []resultExport{*export}), "could not writeJUnit")
+	}
+	fmt.Print("\n----------\n")
+	expired := printExpiredSuppressions(rules)
+	written.check(cmd.Markdown.write(rules, []resultExport{*export}, levels, expired, cmd.FailPolicy, ""), "could not write Markdown summary")
+	if cmd.HTML != "" {
+		written.check(writeHTMLReport(cmd.HTML, rules, []resultExport{*export}, levels, expired, cmd.FailPolicy, ""), "could not writeHTMLReport")
+	}
+	printBudgetVerdict(levels, expired, cmd.FailPolicy, written)
+}
+
+// budgetsSuggestCmd configures the budgets suggest command
+type budgetsSuggestCmd struct {
+	Reports []string `kong:"arg,type='path',help='Saved reports (plain report json or EXPORT_FILE) or directories of them, e.g. a history of EXPORT_FILEs'"`
+
+	WarningPercentile float64  `kong:"default='75',help='Percentile of the historical values the warning thresholds are based on'"`
+	ErrorPercentile   float64  `kong:"default='95',help='Percentile of the historical values the error thresholds are based on'"`
+	Headroom          float64  `kong:"default='10',help='Headroom in percent added on top of the percentiles'"`
+	Metrics           []string `kong:"help='Metrics to suggest budgets for. Defaults to all metrics'"`
+	Per               string   `kong:"default='all',enum='all,device,country,device-country',help='Additionally suggest budgets per device and/or country (all|device|country|device-country)'"`
+	Format            string   `kong:"default='budgets-file',enum='budgets-file,api',help='Write a local budgets file (BUDGETS_FILE) with overrides per group, or a list of performance budgets payloads for the api (budgets-file|api)'"`
+	Output            string   `kong:"required,help='File to write the suggested budgets to'"`
+}
+
+// suggestionGroup is a set of performance reports budgets are suggested for
+type suggestionGroup struct {
+	name    string
+	match   budget.Match
+	reports []vfrogapi.PerformanceReport
+}
+
+// groups splits the performance reports by device and/or country
+func (cmd budgetsSuggestCmd) groups(reports []vfrogapi.PerformanceReport) []suggestionGroup {
+	groups := []suggestionGroup{{name: "all", reports: reports}}
+	if cmd.Per == "all" {
+		return groups
+	}
+
+	byName := map[string]*suggestionGroup{}
+	names := make([]string, 0)
+	for _, report := range reports {
+		g := suggestionGroup{}
+		if cmd.Per == "device" || cmd.Per == "device-country" {
+			g.match.Devices = []vfrogapi.DeviceName{report.Device.Name}
+			g.name = string(report.Device.Name)
+		}
+		if cmd.Per == "country" || cmd.Per == "device-country" {
+			g.match.Countries = []string{strings.ToUpper(report.Country.Code)}
+			g.name = strings.TrimPrefix(fmt.Sprintf("%s-%s", g.name, strings.ToUpper(report.Country.Code)), "-")
+		}
+		if _, ok := byName[g.name]; !ok {
+			byName[g.name] = &g
+			names = append(names, g.name)
+		}
+		byName[g.name].reports = append(byName[g.name].reports, report)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		groups = append(groups, *byName[name])
+	}
+	return groups
+}
+
+// readReports reads all saved reports. Directories are read non recursive, using all .json files
+func readReports(fileNames []string) ([]vfrogapi.PerformanceReport, error) {
+	reports := make([]vfrogapi.PerformanceReport, 0)
+	for _, fileName := range fileNames {
+		stat, err := os.Stat(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("could not stat %q: %w", fileName, err)
+		}
+		files := []string{fileName}
+		if stat.IsDir() {
+			files, err = filepath.Glob(filepath.Join(fileName, "*.json"))
+			if err != nil {
+				return nil, fmt.Errorf("could not list %q: %w", fileName, err)
+			}
+		}
+		for _, f := range files {
+			export, err := readResultExport(f)
+			if err != nil {
+				return nil, fmt.Errorf("could not readResultExport: %w", err)
+			}
+			reports = append(reports, export.Data...)
+		}
+	}
+	return reports, nil
+}
+
+func runBudgetsSuggest(cmd budgetsSuggestCmd) {
+	reports, err := readReports(cmd.Reports)
+	if err != nil {
+		log.Fatalf("could not readReports: %s", err)
+	}
+
+	opts := budget.SuggestOptions{
+		WarningPercentile: cmd.WarningPercentile,
+		ErrorPercentile:   cmd.ErrorPercentile,
+		Headroom:          cmd.Headroom,
+	}
+	for _, metric := range cmd.Metrics {
+		opts.Metrics = append(opts.Metrics, vfrogapi.PerformanceBudgetMetric(metric))
+	}
+
+	localBudgets := budget.Config{}
+	apiBudgets := make([]vfrogapi.PerformanceBudgets, 0)
+	for _, g := range cmd.groups(reports) {
+		suggestions, err := budget.Suggest(g.reports, opts)
+		if err != nil {
+			log.Fatalf("could not Suggest budgets for %q: %s", g.name, err)
+		}
+
+		//
+		// Print the distribution the budgets are based on
+		fmt.Printf("\nSuggested budgets for %s (%d performance reports):\n", g.name, len(g.reports))
+		budgets := make([]vfrogapi.PerformanceBudget, 0, len(suggestions))
+		for _, suggestion := range suggestions {
+			d := suggestion.Distribution
+			info, _ := budget.Info(d.Metric)
+			fmt.Printf("   %-6s min %s, median %s, p75 %s, p95 %s, max %s → warning %s, error %s\n", info.Short, d.Min, d.Median, d.P75, d.P95, d.Max,
+				budget.ThresholdValue(d.Metric, suggestion.Budget.Warning), budget.ThresholdValue(d.Metric, suggestion.Budget.Error))
+			budgets = append(budgets, suggestion.Budget)
+		}
+
+		if g.name == "all" {
+			localBudgets.Budgets = budgets
+		} else {
+			localBudgets.Overrides = append(localBudgets.Overrides, budget.Override{Name: g.name, Match: g.match, Budgets: budgets})
+		}
+		apiBudgets = append(apiBudgets, vfrogapi.PerformanceBudgets{
+			Budgets:     budgets,
+			Description: fmt.Sprintf("Suggested for %s from %d performance reports (p%g/p%g +%g%%)", g.name, len(g.reports), cmd.WarningPercentile, cmd.ErrorPercentile, cmd.Headroom),
+		})
+	}
+
+	var out interface{} = localBudgets
+	if cmd.Format == "api" {
+		out = apiBudgets
+	}
+	err = writeJSONFile(cmd.Output, out)
+	if err != nil {
+		log.Fatalf("could not writeJSONFile: %s", err)
+	}
+	fmt.Printf("\nWrote suggested budgets to %s\n", cmd.Output)
+}
+
+// budgetsImportLighthouseCmd configures the budgets import-lighthouse command
+type budgetsImportLighthouseCmd struct {
+	File      string `kong:"arg,type='existingfile',help='Lighthouse budget.json'"`
+	Output    string `kong:"required,help='Local budgets file (BUDGETS_FILE) to write the budgets for all paths and the path overrides to'"`
+	ApiOutput string `kong:"help='Additionally write the budgets for all paths as performance budgets payload for the api. The path overrides stay local'"`
+}
+
+func runBudgetsImportLighthouse(cmd budgetsImportLighthouseCmd) {
+	lighthouseBudgets, err := budget.ReadLighthouseBudgets(cmd.File)
+	if err != nil {
+		log.Fatalf("could not ReadLighthouseBudgets: %s", err)
+	}
+	performanceBudgets, localBudgets, dropped := budget.FromLighthouse(lighthouseBudgets)
+	localBudgets.Budgets = performanceBudgets.Budgets
+
+	err = writeJSONFile(cmd.Output, localBudgets)
+	if err != nil {
+		log.Fatalf("could not writeJSONFile: %s", err)
+	}
+	fmt.Printf("Wrote %d budgets for all paths and %d path overrides to %s\n", len(localBudgets.Budgets), len(localBudgets.Overrides), cmd.Output)
+	if cmd.ApiOutput != "" {
+		err = writeJSONFile(cmd.ApiOutput, performanceBudgets)
+		if err != nil {
+			log.Fatalf("could not writeJSONFile: %s", err)
+		}
+		fmt.Printf("Wrote the performance budgets payload to %s\n", cmd.ApiOutput)
+	}
+	printDropped(dropped)
+}
+
+// budgetsExportLighthouseCmd configures the budgets export-lighthouse command
+type budgetsExportLighthouseCmd struct {
+	PerformanceBudgets string `kong:"type='existingfile',help='Performance budgets payload of the api (json) to use as budgets for all paths'"`
+	Preset             string `kong:"help='Built-in budgets to use as budgets for all paths instead (google-cwv-good|google-cwv-needs-improvement)'"`
+	Budgets            string `kong:"type='existingfile',help='Local budgets file layered over the performance budgets'"`
+	Output             string `kong:"required,help='Lighthouse budget.json to write'"`
+}
+
+func runBudgetsExportLighthouse(cmd budgetsExportLighthouseCmd) {
+	var performanceBudgets *vfrogapi.PerformanceBudgets
+	switch {
+	case cmd.PerformanceBudgets != "" && cmd.Preset != "":
+		log.Fatal("configCheck failed: use either --performance-budgets or --preset")
+	case cmd.Preset != "":
+		var ok bool
+		performanceBudgets, ok = budget.Preset(cmd.Preset)
+		if !ok {
+			log.Fatalf("configCheck failed: unknown preset %q. Use one of %v", cmd.Preset, budget.PresetNames())
+		}
+	case cmd.PerformanceBudgets != "":
+		raw, err := os.ReadFile(cmd.PerformanceBudgets)
+		if err != nil {
+			log.Fatalf("could not read %q: %s", cmd.PerformanceBudgets, err)
+		}
+		performanceBudgets = &vfrogapi.PerformanceBudgets{}
+		if err := json.Unmarshal(raw, performanceBudgets); err != nil {
+			log.Fatalf("could not json unmarshal %q: %s", cmd.PerformanceBudgets, err)
+		}
+	}
+	var localBudgets *budget.Config
+	if cmd.Budgets != "" {
+		var err error
+		localBudgets, err = budget.ReadConfig(cmd.Budgets)
+		if err != nil {
+			log.Fatalf("could not ReadConfig: %s", err)
+		}
+	}
+	if performanceBudgets == nil && localBudgets == nil {
+		log.Fatal("configCheck failed: nothing to export. Use --performance-budgets, --preset or --budgets")
+	}
+
+	lighthouseBudgets, dropped := budget.ToLighthouse(performanceBudgets, localBudgets)
+	err := writeJSONFile(cmd.Output, lighthouseBudgets)
+	if err != nil {
+		log.Fatalf("could not writeJSONFile: %s", err)
+	}
+	fmt.Printf("Wrote %d Lighthouse budgets to %s\n", len(lighthouseBudgets), cmd.Output)
+	printDropped(dropped)
+}
+
+// printDropped lists everything a conversion could not represent
+func printDropped(dropped []string) {
+	if len(dropped) == 0 {
+		return
+	}
+	yellow.Print("\nNot represented by the conversion:\n")
+	for _, d := range dropped {
+		yellow.Printf("   ⚠ %s\n", d)
+	}
+}
+
+// writeJSONFile writes v indented as json
+func writeJSONFile(fileName string, v interface{}) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not json marshal: %w", err)
+	}
+	err = os.WriteFile(fileName, raw, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not write %q: %w", fileName, err)
+	}
+	return nil
+}
@@ -3,24 +3,66 @@ package main
 import (
 	"fmt"
 	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
 	"github.com/alecthomas/kong"
 	log "github.com/sirupsen/logrus"
+	"os"
 	"strings"
+	"time"
 )
 
+// cli holds all commands of the VitalFrog cli. Without a command, run is executed
+type cli struct {
+	Run     config     `kong:"cmd,default='withargs',help='Create a new report and check it against the performance budgets'"`
+	Merge   mergeCmd   `kong:"cmd,help='Merge saved results (EXPORT_FILE) of sharded runs into a single table, verdict and export'"`
+	Budgets budgetsCmd `kong:"cmd,help='Work with budgets offline, without creating a report'"`
+
+	LogLevel string `kong:"default='info',enum='error,info,debug',env='LOG_LEVEL',help='Log level'"`
+
+	command string
+}
+
+// config configures the run command
 type config struct {
 	APIBaseUrl string `kong:"default='https://api.vitalfrog.com/v2',env='API_BASE_URL',help='API Address of the VitalFrog api'"`
 	APIToken   string `kong:"required,env='API_TOKEN',help='Your VitalFrog api token'"`
 
-	AllowedCountries []string `kong:"env='ALLOWED_COUNTRIES',help='Which countries to test from. Either ALLOWED_COUNTRIES or BlOCKED_COUNTRIES can be set, not both.'"`
-	BlockedCountries []string `kong:"env='BlOCKED_COUNTRIES',help='Which countries NOT to test from. Either ALLOWED_COUNTRIES or BlOCKED_COUNTRIES can be set, not both.'"`
+	AllowedCountries []string `kong:"env='ALLOWED_COUNTRIES',help='Which countries to test from (ISO 3166-1 alpha-2 codes or the groups EU, NA, APAC). Either ALLOWED_COUNTRIES or BLOCKED_COUNTRIES can be set, not both.'"`
+	BlockedCountries []string `kong:"env='BLOCKED_COUNTRIES',help='Which countries NOT to test from (ISO 3166-1 alpha-2 codes or the groups EU, NA, APAC). Either ALLOWED_COUNTRIES or BLOCKED_COUNTRIES can be set, not both.'"`
 
 	PerformanceBudgetsId int32    `kong:"env='PERFORMANCE_BUDGETS_ID',help='Performance budgets to use. If not defined falls back to VitalFrog default.'"`
 	Devices              []string `kong:"env='DEVICES',help='Which devices you want to test for. If not set falls back to desktop & mobile'"`
 
+	BudgetsPreset string `kong:"env='BUDGETS_PRESET',help='Built-in budgets to use instead of PERFORMANCE_BUDGETS_ID (google-cwv-good|google-cwv-needs-improvement)'"`
+	CWVAssessment bool   `kong:"default='true',negatable,env='CWV_ASSESSMENT',help='Print the Core Web Vitals assessment per path and device below the table'"`
+
+	BudgetsFallback       string `kong:"default='account-default',enum='account-default,preset,report-only',env='BUDGETS_FALLBACK',help='Budgets to judge against if the report has no PERFORMANCE_BUDGETS_ID: the default performance budgets of the account, BUDGETS_FALLBACK_PRESET or none (account-default|preset|report-only)'"`
+	BudgetsFallbackPreset string `kong:"default='google-cwv-needs-improvement',env='BUDGETS_FALLBACK_PRESET',help='Built-in budgets to use for BUDGETS_FALLBACK=preset (google-cwv-good|google-cwv-needs-improvement)'"`
+
+	ScoreWeighting string `kong:"default='v10',enum='v6,v8,v10',env='SCORE_WEIGHTING',help='Lighthouse version whose weights and curves the performance score is computed with (v6|v8|v10)'"`
+
+	BudgetsFile string `kong:"env='BUDGETS_FILE',help='Local budgets file layered over the performance budgets. Supports overrides per path glob, device and country'"`
+
+	SuppressionsFile string `kong:"env='SUPPRESSIONS_FILE',help='JSON file of known budget violations (path glob, metric, device, country, reason, owner, expires). Matching failures are downgraded to warnings. Expired suppressions fail the run'"`
+
+	BaselineFile       string `kong:"env='BASELINE_FILE',help='Saved report (EXPORT_FILE) to compare the regressions of BUDGETS_FILE against'"`
+	BaselineReportUuid string `kong:"env='BASELINE_REPORT_UUID',help='Uuid of a report (e.g. of the reference Version of this component) to compare the regressions of BUDGETS_FILE against'"`
+	BaselineVersion    string `kong:"env='BASELINE_VERSION',help='Compare the regressions of BUDGETS_FILE against the latest finished report of COMPONENT_NAME on this reference Version'"`
+
 	TargetHost       string   `kong:"required,env='TARGET_HOST',help='Host of url you want to test'"`
 	TargetSchemeHost string   `kong:"default='https',enum='https,http',env='TARGET_SCHEMA',help='What schema (http|https) to use on target host'"`
-	TargetPaths      []string `kong:"required,env='TARGET_PATHS',help='Paths to test'"`
+	TargetPaths      []string `kong:"env='TARGET_PATHS',help='Paths to test'"`
+
+	PathsFile    string   `kong:"env='PATHS_FILE',help='Newline delimited file of paths to test. Added to TARGET_PATHS'"`
+	PathsSitemap string   `kong:"env='PATHS_SITEMAP',help='Local sitemap.xml (or sitemap index, optionally gzipped) to read paths to test from. Added to TARGET_PATHS'"`
+	PathsDir     string   `kong:"env='PATHS_DIR',help='Static site build directory (e.g. dist/) to read paths from. index.html files are mapped to their route, the error and fallback pages 200.html, 404.html, 500.html and 50x.html are skipped. Added to TARGET_PATHS'"`
+	PathsInclude []string `kong:"env='PATHS_INCLUDE',help='Only test paths matching at least one of these globs. A single star matches within a path segment, a double star across segments'"`
+	PathsExclude []string `kong:"env='PATHS_EXCLUDE',help='Do not test paths matching any of these globs'"`
+
+	SampleSize          int      `kong:"env='SAMPLE_SIZE',help='Only test this many of the configured paths per run. 0 tests all paths'"`
+	SamplePinned        []string `kong:"env='SAMPLE_PINNED',help='Paths which are always part of the sample'"`
+	SampleStratifyDepth int      `kong:"env='SAMPLE_STRATIFY_DEPTH',help='Spread the sample evenly over url prefixes of this many path segments. 0 disables stratification'"`
+	SampleStateFile     string   `kong:"env='SAMPLE_STATE_FILE',help='Local file to keep the rotation state in. Successive runs then cover every path once per cycle'"`
 
 	Version       string `kong:"env='VERSION',help='Version of the given code. Good for later tracing'"`
 	ComponentName string `kong:"env='COMPONENT_NAME',help='Name of the component we are testing. Helps to figure out cross repo problems. Good for later tracing'"`
@@ -30,22 +72,53 @@ type config struct {
 
 	ExtraHeaders map[string]string `kong:"env='EXTRA_HEADERS',help='Additional headers to set on the request. Mostly used for auth reasons'"`
 
-	RunAsync bool   `kong:"env='RUN_ASYNC',help='Configure if the request should run async, to not block execution. Report must be checked in browser then later'"`
-	LogLevel string `kong:"default='info',enum='error,info,debug',env='LOG_LEVEL',help='Log level'"`
-}
+	PathGroupsFile string `kong:"env='PATH_GROUPS_FILE',help='JSON file of path groups with their own basic auth and extra headers, layered over the global ones. An empty header value removes a global header, inherit false drops all global basic auth and headers. One report is created per group and merged into a single table'"`
 
-func parseEnvironmentToConfig() (*config, error) {
-	cfg := config{}
+	ShardIndex      int  `kong:"env='SHARD_INDEX',help='Zero based index of this job, if paths are split across parallel CI jobs. Requires SHARD_TOTAL'"`
+	ShardTotal      int  `kong:"env='SHARD_TOTAL',help='Number of parallel CI jobs the paths are split across'"`
+	ShardAutoDetect bool `kong:"default='true',negatable,env='SHARD_AUTO_DETECT',help='Detect SHARD_INDEX/SHARD_TOTAL from the parallelism env vars of CircleCI, GitLab, Buildkite, Semaphore and Azure Pipelines'"`
 
-	kong.Parse(&cfg)
-	err := cfg.check()
-	if err != nil {
-		return nil, fmt.Errorf("configCheck failed: %w", err)
+	Repeat            int    `kong:"default='1',env='REPEAT',help='Test every path, device and country this many times and aggregate the runs before evaluating the budgets. Reduces flaky results'"`
+	RepeatAggregate   string `kong:"default='median',enum='median,p75,min',env='REPEAT_AGGREGATE',help='How the runs of REPEAT are aggregated (median|p75|min)'"`
+	RepeatOnlyFailing bool   `kong:"env='REPEAT_ONLY_FAILING',help='Only repeat the path, device and country combinations with failing budgets, instead of all of them. Creates a report per failing device and country'"`
+
+	ExportFile string          `kong:"env='EXPORT_FILE',help='Save the finished report and its performance budgets as json to this file. Can be merged later with the merge command'"`
+	Output     string          `kong:"default='none',enum='none,json,jsonl',env='OUTPUT',help='Machine readable output written to OUTPUT_FILE next to the table. json writes a single document at the end, jsonl streams a line per row as it arrives (none|json|jsonl)'"`
+	OutputFile string          `kong:"env='OUTPUT_FILE',help='File to write the OUTPUT to'"`
+	Markdown   markdownSummary `kong:"embed"`
+	JUnitFile  string          `kong:"name='junit',env='JUNIT_FILE',help='Write the budget verdicts as JUnit XML to this file. Every path, device and country is a testsuite, every budgeted metric a testcase'"`
+	HTMLFile   string          `kong:"name='html',env='HTML_FILE',help='Write a self-contained HTML report to this file, e.g. to store it as CI artifact. Works offline'"`
+
+	FailPolicy failPolicy    `kong:"embed"`
+	Timeout    time.Duration `kong:"env='TIMEOUT',help='Give up waiting for the reports after this duration (e.g. 30m). 0 waits forever'"`
+
+	RunAsync bool `kong:"env='RUN_ASYNC',help='Configure if the request should run async, to not block execution. Report must be checked in browser then later'"`
+}
+
+// commandName strips the positional arguments from a kong command. E.g. "merge <files>" is "merge"
+func commandName(command string) string {
+	parts := make([]string, 0)
+	for _, field := range strings.Fields(command) {
+		if strings.HasPrefix(field, "<") {
+			break
+		}
+		parts = append(parts, field)
 	}
-	return &cfg, nil
+	return strings.Join(parts, " ")
 }
 
-func (c config) check() error {
+// parseCLI parses the command line and environment and sets the log level
+func parseCLI() *cli {
+	c := cli{}
+
+	ctx := kong.Parse(&c, kong.Exit(func(code int) {
+		if code != 0 {
+			code = exitError
+		}
+		os.Exit(code)
+	}))
+	c.command = commandName(ctx.Command())
+
 	switch c.LogLevel {
 	case "error":
 		log.SetLevel(log.ErrorLevel)
@@ -53,15 +126,49 @@ func (c config) check() error {
 		log.SetLevel(log.InfoLevel)
 	case "debug":
 		log.SetLevel(log.DebugLevel)
-	default:
-		return fmt.Errorf("invalid LOG_LEVEL: %q", c.LogLevel)
 	}
+	return &c
+}
+
+// prepare resolves the paths and countries to test and checks the config
+func (c *config) prepare() (*config, error) {
+	// BLOCKED_COUNTRIES used to be read from the misspelled BlOCKED_COUNTRIES
+	if legacy := os.Getenv("BlOCKED_COUNTRIES"); legacy != "" && len(c.BlockedCountries) == 0 {
+		log.Warnf("BlOCKED_COUNTRIES is deprecated, use BLOCKED_COUNTRIES instead")
+		c.BlockedCountries = strings.Split(legacy, ",")
+	}
+
+	allowedCountries, err := expandCountries(c.AllowedCountries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALLOWED_COUNTRIES: %w", err)
+	}
+	c.AllowedCountries = allowedCountries
+
+	blockedCountries, err := expandCountries(c.BlockedCountries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BLOCKED_COUNTRIES: %w", err)
+	}
+	c.BlockedCountries = blockedCountries
 
+	targetPaths, err := c.resolveTargetPaths()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolveTargetPaths: %w", err)
+	}
+	c.TargetPaths = targetPaths
+
+	err = c.check()
+	if err != nil {
+		return nil, fmt.Errorf("configCheck failed: %w", err)
+	}
+	return c, nil
+}
+
+func (c config) check() error {
 	if (c.BasicAuthUsername == "" && c.BasicAuthPassword != "") || (c.BasicAuthUsername != "" && c.BasicAuthPassword == "") {
 		return fmt.Errorf("both BASIC_AUTH_PASSWORD and BASIC_AUTH_USERNAME must be configure if one of them is set")
 	}
 	if len(c.TargetPaths) == 0 {
-		return fmt.Errorf("at least 1 TARGET_PATH must be set")
+		return fmt.Errorf("at least 1 path must be set via TARGET_PATHS, PATHS_FILE, PATHS_SITEMAP or PATHS_DIR (after applying PATHS_INCLUDE/PATHS_EXCLUDE)")
 	}
 
 	if c.TargetHost == "" {
@@ -69,7 +176,73 @@ func (c config) check() error {
 	}
 
 	if len(c.AllowedCountries) > 0 && len(c.BlockedCountries) > 0 {
-		return fmt.Errorf("either ALLOWED_COUNTRIES or BlOCKED_COUNTRIES can be set, not both")
+		return fmt.Errorf("either ALLOWED_COUNTRIES or BLOCKED_COUNTRIES can be set, not both")
+	}
+
+	if c.SampleSize < 0 || c.SampleStratifyDepth < 0 {
+		return fmt.Errorf("SAMPLE_SIZE and SAMPLE_STRATIFY_DEPTH must not be negative")
+	}
+	if c.SampleSize > 0 && len(c.SamplePinned) > c.SampleSize {
+		return fmt.Errorf("SAMPLE_PINNED has %d paths, but SAMPLE_SIZE is only %d", len(c.SamplePinned), c.SampleSize)
+	}
+
+	if c.ShardTotal < 0 || c.ShardIndex < 0 || (c.ShardTotal > 0 && c.ShardIndex >= c.ShardTotal) {
+		return fmt.Errorf("SHARD_INDEX must be between 0 and SHARD_TOTAL-1")
+	}
+
+	if c.BudgetsPreset != "" {
+		if _, ok := budget.Preset(c.BudgetsPreset); !ok {
+			return fmt.Errorf("unknown BUDGETS_PRESET %q. Use one of %s", c.BudgetsPreset, strings.Join(budget.PresetNames(), ", "))
+		}
+		if c.PerformanceBudgetsId != 0 {
+			return fmt.Errorf("either PERFORMANCE_BUDGETS_ID or BUDGETS_PRESET can be set, not both")
+		}
+	}
+	if _, ok := budget.Preset(c.BudgetsFallbackPreset); c.BudgetsFallback == "preset" && !ok {
+		return fmt.Errorf("unknown BUDGETS_FALLBACK_PRESET %q. Use one of %s", c.BudgetsFallbackPreset, strings.Join(budget.PresetNames(), ", "))
+	}
+
+	baselines := 0
+	for _, b := range []string{c.BaselineFile, c.BaselineReportUuid, c.BaselineVersion} {
+		if b != "" {
+			baselines++
+		}
+	}
+	if baselines > 1 {
+		return fmt.Errorf("only one of BASELINE_FILE, BASELINE_REPORT_UUID or BASELINE_VERSION can be set")
+	}
+	if c.BaselineVersion != "" && c.ComponentName == "" {
+		return fmt.Errorf("BASELINE_VERSION requires COMPONENT_NAME, as the reports of the component are searched")
+	}
+
+	if c.Repeat < 1 {
+		return fmt.Errorf("REPEAT must be at least 1")
+	}
+	if c.Repeat > 1 && c.RunAsync {
+		return fmt.Errorf("REPEAT can not be used with RUN_ASYNC, as the runs are not awaited")
+	}
+
+	if c.ExportFile != "" && c.RunAsync {
+		return fmt.Errorf("EXPORT_FILE can not be used with RUN_ASYNC, as the report is not awaited")
+	}
+	if c.Output != "none" && c.OutputFile == "" {
+		return fmt.Errorf("OUTPUT requires OUTPUT_FILE, as the table is written to stdout")
+	}
+	if c.Output != "none" && c.RunAsync {
+		return fmt.Errorf("OUTPUT can not be used with RUN_ASYNC, as the report is not awaited")
+	}
+	if c.JUnitFile != "" && c.RunAsync {
+		return fmt.Errorf("JUNIT_FILE can not be used with RUN_ASYNC, as the report is not awaited")
+	}
+	if c.HTMLFile != "" && c.RunAsync {
+		return fmt.Errorf("HTML_FILE can not be used with RUN_ASYNC, as the report is not awaited")
+	}
+
+	if err := c.FailPolicy.check(); err != nil {
+		return err
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("TIMEOUT must not be negative")
 	}
 
 	if strings.HasSuffix(c.APIBaseUrl, "/") {
@@ -103,19 +276,19 @@ func (c config) ToReportConfig() vfrogapi.ReportConfig {
 		reportConfig.Version = &c.Version
 	}
 
-	// Configure allowed countries
+	// Configure allowed or blocked countries
 	if len(c.AllowedCountries) > 0 || len(c.BlockedCountries) > 0 {
 		newCountries := vfrogapi.Countries{
 			List: make([]vfrogapi.Country, 0),
 			Mode: vfrogapi.AllowList,
 		}
+		countryCodes := c.AllowedCountries
 		if len(c.BlockedCountries) > 0 {
 			newCountries.Mode = vfrogapi.BlockList
+			countryCodes = c.BlockedCountries
 		}
-		for _, c := range c.AllowedCountries {
-			newCountries.List = append(newCountries.List, vfrogapi.Country{
-				Code: c,
-			})
+		for _, code := range countryCodes {
+			newCountries.List = append(newCountries.List, newCountry(code))
 		}
 		reportConfig.Countries = &newCountries
 	}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"sort"
+	"strings"
+)
+
+// countriesTSV is the ISO 3166-1 alpha-2 table. One "<code>\t<name>" per line
+//
+//go:embed countries.tsv
+var countriesTSV string
+
+// countryNames maps ISO 3166-1 alpha-2 codes to the country name
+var countryNames = parseCountriesTSV(countriesTSV)
+
+// countryGroups are named regions which can be used in ALLOWED_COUNTRIES/BLOCKED_COUNTRIES instead of single codes.
+// Group names take precedence over country codes. Prefix a code with '=' to select the country ('=NA' is Namibia).
+var countryGroups = map[string][]string{
+	"EU": {
+		"AT", "BE", "BG", "HR", "CY", "CZ", "DK", "EE", "FI", "FR", "DE", "GR", "HU", "IE",
+		"IT", "LV", "LT", "LU", "MT", "NL", "PL", "PT", "RO", "SK", "SI", "ES", "SE",
+	},
+	"NA": {"US", "CA", "MX"},
+	"APAC": {
+		"AU", "NZ", "JP", "KR", "CN", "HK", "TW", "SG", "MY", "TH", "ID", "PH", "VN", "IN",
+	},
+}
+
+func parseCountriesTSV(tsv string) map[string]string {
+	names := map[string]string{}
+	for _, line := range strings.Split(tsv, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		names[fields[0]] = fields[1]
+	}
+	return names
+}
+
+// expandCountries expands country groups into their codes, upper cases and deduplicates the codes and checks them
+// against ISO 3166-1 alpha-2
+func expandCountries(input []string) ([]string, error) {
+	seen := map[string]struct{}{}
+	codes := make([]string, 0, len(input))
+	add := func(code string) {
+		if _, ok := seen[code]; ok {
+			return
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+
+	for _, v := range input {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if strings.HasPrefix(v, "=") {
+			v = strings.TrimPrefix(v, "=")
+		} else if group, ok := countryGroups[v]; ok {
+			for _, code := range group {
+				add(code)
+			}
+			continue
+		}
+		if _, ok := countryNames[v]; !ok {
+			return nil, fmt.Errorf("unknown country %q. Use an ISO 3166-1 alpha-2 code or one of the groups %s", v, strings.Join(countryGroupNames(), ", "))
+		}
+		add(v)
+	}
+	return codes, nil
+}
+
+func countryGroupNames() []string {
+	names := make([]string, 0, len(countryGroups))
+	for name := range countryGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newCountry returns the country with its name filled in, if the code is known
+func newCountry(code string) vfrogapi.Country {
+	country := vfrogapi.Country{Code: code}
+	if name, ok := countryNames[code]; ok {
+		country.Name = &name
+	}
+	return country
+}
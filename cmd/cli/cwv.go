@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"github.com/fatih/color"
+	"github.com/vitalfrog/termtable"
+	"io"
+)
+
+// writeCWVAssessment writes the Core Web Vitals assessment per path and device, like Search Console reports it
+func writeCWVAssessment(w io.Writer, reports []vfrogapi.PerformanceReport) {
+	assessments := budget.Assess(reports)
+	if len(assessments) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, "\nCore Web Vitals assessment (75th percentile over all countries):\n\n")
+	header := []termtable.HeaderField{
+		{
+			Field: termtable.NewStringField("Path"),
+		},
+		{
+			Field: termtable.NewStringField("Device"),
+			Width: termtable.IntPointer(10),
+		},
+	}
+	for _, metric := range budget.CoreWebVitals() {
+		title := string(metric)
+		if info, ok := budget.Info(metric); ok {
+			title = info.Short
+		}
+		header = append(header, termtable.HeaderField{
+			Field: termtable.NewStringField(title),
+			Width: termtable.IntPointer(26),
+		})
+	}
+	header = append(header, termtable.HeaderField{
+		Field: termtable.NewStringField("Assessment"),
+		Width: termtable.IntPointer(10),
+	})
+
+	tt := termtable.New(w, " | ")
+	tt.WriteHeader(header)
+	tt.WriteRowDivider('=')
+
+	passed := 0
+	for _, a := range assessments {
+		row := []termtable.Field{
+			termtable.NewStringField(a.Path),
+			termtable.NewStringField(string(a.Device)),
+		}
+		for _, r := range a.Ratings {
+			row = append(row, termtable.NewColorField(r.String(), ratingColor(r.Rating)))
+		}
+		if a.Passed {
+			passed++
+			row = append(row, termtable.NewColorField("passed", green))
+		} else {
+			row = append(row, termtable.NewColorField("✖ failed", red))
+		}
+		tt.WriteRow(row)
+	}
+	tt.WriteRowDivider('-')
+	fmt.Fprintf(w, "%d of %d paths and devices pass the Core Web Vitals assessment\n", passed, len(assessments))
+}
+
+func ratingColor(rating budget.Rating) *color.Color {
+	switch rating {
+	case budget.RatingGood:
+		return green
+	case budget.RatingNeedsImprovement:
+		return yellow
+	}
+	return red
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	log "github.com/sirupsen/logrus"
+	"os"
+)
+
+// Exit codes of the cli. Documented in the README
+const (
+	// exitBudgetFailure means the budgets failed according to the failPolicy
+	exitBudgetFailure = 1
+	// exitError means the config is invalid or the VitalFrog API could not be reached
+	exitError = 2
+	// exitTimeout means the reports were not finished within TIMEOUT
+	exitTimeout = 3
+	// exitCancelled means the run was interrupted (SIGINT/SIGTERM)
+	exitCancelled = 4
+)
+
+// failPolicy decides when budget violations fail the run. Shared by the run and merge command
+type failPolicy struct {
+	FailOn         string  `kong:"default='error',enum='error,warning,never',env='FAIL_ON',help='Which budget level fails the run (error|warning|never)'"`
+	FailMinRows    int     `kong:"default='1',env='FAIL_MIN_ROWS',help='Only fail if at least this many rows reach the FAIL_ON level'"`
+	FailMinPercent float64 `kong:"env='FAIL_MIN_PERCENT',help='Only fail if at least this percentage of rows reaches the FAIL_ON level'"`
+}
+
+func (p failPolicy) check() error {
+	if p.FailMinRows < 1 {
+		return fmt.Errorf("FAIL_MIN_ROWS must be at least 1")
+	}
+	if p.FailMinPercent < 0 || p.FailMinPercent > 100 {
+		return fmt.Errorf("FAIL_MIN_PERCENT must be between 0 and 100")
+	}
+	return nil
+}
+
+// level returns the budget level rows need to reach to count as failing
+func (p failPolicy) level() budget.Level {
+	if p.FailOn == "warning" {
+		return budget.LevelWarn
+	}
+	return budget.LevelFail
+}
+
+// fails reports whether the rows fail the run. Expired suppressions always fail the run, even with FAIL_ON never, so
+// they are renewed or removed instead of silently judging the suppressed metrics again
+func (p failPolicy) fails(levels rowLevels, expiredSuppressions int) bool {
+	if expiredSuppressions > 0 {
+		return true
+	}
+	if p.FailOn == "never" {
+		return false
+	}
+	failing := levels.atLeast(p.level())
+	if failing == 0 || failing < p.FailMinRows {
+		return false
+	}
+	return float64(failing)/float64(levels.total())*100 >= p.FailMinPercent
+}
+
+// rowLevels counts the table rows per budget level
+type rowLevels map[budget.Level]int
+
+// highest returns the highest level of any row
+func (l rowLevels) highest() budget.Level {
+	highest := budget.LevelOk
+	for level, count := range l {
+		if count > 0 && level > highest {
+			highest = level
+		}
+	}
+	return highest
+}
+
+// atLeast returns the number of rows at the level or above
+func (l rowLevels) atLeast(level budget.Level) int {
+	count := 0
+	for k, v := range l {
+		if k >= level {
+			count += v
+		}
+	}
+	return count
+}
+
+func (l rowLevels) total() int {
+	return l.atLeast(budget.LevelOk)
+}
+
+// resultFiles counts the result files (OUTPUT_FILE, EXPORT_FILE, Markdown, JUnit, HTML) which could not be written.
+// CI gates read them, so a missing one exits with exitError instead of the budget verdict
+type resultFiles struct {
+	failed int
+}
+
+// check logs the error of writing a result file. Nil errors are ignored
+func (f *resultFiles) check(err error, message string) {
+	if err == nil {
+		return
+	}
+	log.Errorf("%s: %s", message, err)
+	f.failed++
+}
+
+// err returns an error if any result file could not be written
+func (f resultFiles) err() error {
+	if f.failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("could not write %d result files", f.failed)
+}
+
+// exitCode returns the exit code of an error aborting the run
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		return exitCancelled
+	}
+	return exitError
+}
+
+// exitWithError logs the error and exits with its exit code
+func exitWithError(err error) {
+	log.Errorf("%s", err)
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"testing"
+)
+
+func TestFailPolicyFails(t *testing.T) {
+	// 10 rows: 6 ok, 2 warnings, 2 failures
+	levels := rowLevels{budget.LevelOk: 6, budget.LevelWarn: 2, budget.LevelFail: 2}
+	tests := []struct {
+		name    string
+		policy  failPolicy
+		levels  rowLevels
+		expired int
+		want    bool
+	}{
+		{"error", failPolicy{FailOn: "error", FailMinRows: 1}, levels, 0, true},
+		{"error without failures", failPolicy{FailOn: "error", FailMinRows: 1}, rowLevels{budget.LevelOk: 8, budget.LevelWarn: 2}, 0, false},
+		{"warning counts failures and warnings", failPolicy{FailOn: "warning", FailMinRows: 4}, levels, 0, true},
+		{"warning without warnings", failPolicy{FailOn: "warning", FailMinRows: 1}, rowLevels{budget.LevelOk: 10}, 0, false},
+		{"never", failPolicy{FailOn: "never", FailMinRows: 1}, levels, 0, false},
+		{"never still fails on expired suppressions", failPolicy{FailOn: "never", FailMinRows: 1}, levels, 1, true},
+		{"expired suppressions fail", failPolicy{FailOn: "error", FailMinRows: 1}, rowLevels{budget.LevelOk: 10}, 1, true},
+		{"min rows reached", failPolicy{FailOn: "error", FailMinRows: 2}, levels, 0, true},
+		{"min rows not reached", failPolicy{FailOn: "error", FailMinRows: 3}, levels, 0, false},
+		{"min percent reached", failPolicy{FailOn: "error", FailMinRows: 1, FailMinPercent: 20}, levels, 0, true},
+		{"min percent not reached", failPolicy{FailOn: "error", FailMinRows: 1, FailMinPercent: 20.1}, levels, 0, false},
+		{"min percent of warnings", failPolicy{FailOn: "warning", FailMinRows: 1, FailMinPercent: 40}, levels, 0, true},
+		{"min rows and percent both needed", failPolicy{FailOn: "warning", FailMinRows: 5, FailMinPercent: 10}, levels, 0, false},
+		{"no rows", failPolicy{FailOn: "error", FailMinRows: 1}, rowLevels{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.fails(tt.levels, tt.expired); got != tt.want {
+				t.Errorf("fails() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailPolicyCheck(t *testing.T) {
+	tests := []struct {
+		policy failPolicy
+		ok     bool
+	}{
+		{failPolicy{FailOn: "error", FailMinRows: 1}, true},
+		{failPolicy{FailOn: "error", FailMinRows: 1, FailMinPercent: 100}, true},
+		{failPolicy{FailOn: "error", FailMinRows: 0}, false},
+		{failPolicy{FailOn: "error", FailMinRows: 1, FailMinPercent: -1}, false},
+		{failPolicy{FailOn: "error", FailMinRows: 1, FailMinPercent: 101}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.check(); (err == nil) != tt.ok {
+			t.Errorf("check(%+v) = %v, want ok %v", tt.policy, err, tt.ok)
+		}
+	}
+}
+
+func TestRowLevels(t *testing.T) {
+	levels := rowLevels{budget.LevelOk: 3, budget.LevelWarn: 2, budget.LevelFail: 0}
+	if levels.highest() != budget.LevelWarn {
+		t.Errorf("highest() = %s, want warn", levels.highest())
+	}
+	if levels.atLeast(budget.LevelWarn) != 2 || levels.total() != 5 {
+		t.Errorf("atLeast(warn) = %d, total() = %d, want 2 and 5", levels.atLeast(budget.LevelWarn), levels.total())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("could not wait: %w", context.DeadlineExceeded), exitTimeout},
+		{fmt.Errorf("could not wait: %w", context.Canceled), exitCancelled},
+		{fmt.Errorf("could not create report"), exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestResultFiles(t *testing.T) {
+	var written resultFiles
+	written.check(nil, "could not write OUTPUT_FILE")
+	if err := written.err(); err != nil {
+		t.Errorf("err() = %v, want nil", err)
+	}
+	written.check(fmt.Errorf("permission denied"), "could not writeJUnit")
+	written.check(fmt.Errorf("no space left"), "could not writeHTMLReport")
+	if err := written.err(); err == nil || exitCode(err) != exitError {
+		t.Errorf("err() = %v, want an error exiting with %d", err, exitError)
+	}
+}
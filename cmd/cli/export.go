@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/score"
+	"os"
+	"time"
+)
+
+// resultExport is the json saved to EXPORT_FILE. It is a vfrogapi.Report with the performance budgets it was judged
+// against, so it can be read as plain report as well.
+type resultExport struct {
+	vfrogapi.Report
+	PerformanceBudgets *vfrogapi.PerformanceBudgets `json:"performance_budgets,omitempty"`
+	// Local budgets file layered over the PerformanceBudgets
+	BudgetsConfig *budget.Config `json:"budgets_config,omitempty"`
+	// Path group name by performance report id. Only set if path groups are configured
+	Groups map[int32]string `json:"groups,omitempty"`
+	// Baseline performance reports the regressions were compared against
+	Baseline []vfrogapi.PerformanceReport `json:"baseline,omitempty"`
+	// Suppressions of known budget violations. Expiry is checked again when the export is judged
+	Suppressions []budget.Suppression `json:"suppressions,omitempty"`
+	// Scoring is the weighting version of the performance scores
+	Scoring string `json:"scoring,omitempty"`
+	// Performance score by performance report id
+	Scores map[int32]float64 `json:"scores,omitempty"`
+	// Mean performance score of all devices and countries by path
+	PathScores map[string]float64 `json:"path_scores,omitempty"`
+}
+
+// newResultExport creates the export of a single report. All its performance reports are labeled with the path group
+func newResultExport(report vfrogapi.Report, group string, rules budget.Rules) resultExport {
+	export := resultExport{Report: report, PerformanceBudgets: rules.Server(), BudgetsConfig: rules.Local(), Suppressions: rules.Suppressions()}
+	export.setScores(rules.Scoring())
+	for _, baselineReport := range rules.Baseline() {
+		export.Baseline = append(export.Baseline, baselineReport)
+	}
+	if group != "" {
+		export.Groups = map[int32]string{}
+		for _, performanceReport := range report.Data {
+			export.Groups[performanceReport.Id] = group
+		}
+	}
+	return export
+}
+
+// setScores computes the performance scores of all performance reports and paths
+func (e *resultExport) setScores(w score.Weighting) {
+	e.Scoring = w.Version
+	e.Scores = map[int32]float64{}
+	for _, performanceReport := range e.Data {
+		e.Scores[performanceReport.Id] = w.Score(performanceReport)
+	}
+	e.PathScores = w.PathScores(e.Data)
+}
+
+// rules returns the budget rules the export was judged against
+func (e resultExport) rules() budget.Rules {
+	rules := budget.NewRules(e.PerformanceBudgets, e.BudgetsConfig)
+	if w, err := score.Lookup(e.Scoring); err == nil {
+		rules = rules.WithScoring(w)
+	}
+	if len(e.Baseline) > 0 {
+		rules = rules.WithBaseline(budget.NewBaseline(e.Baseline))
+	}
+	return rules.WithSuppressions(e.Suppressions, time.Now())
+}
+
+func writeResultExport(fileName string, export resultExport) error {
+	raw, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not json marshal export: %w", err)
+	}
+	err = os.WriteFile(fileName, raw, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not write %q: %w", fileName, err)
+	}
+	return nil
+}
+
+func readResultExport(fileName string) (*resultExport, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+	export := &resultExport{}
+	err = json.Unmarshal(raw, export)
+	if err != nil {
+		return nil, fmt.Errorf("could not json unmarshal %q: %w", fileName, err)
+	}
+	if export.BudgetsConfig != nil {
+		if err := export.BudgetsConfig.Compile(); err != nil {
+			return nil, fmt.Errorf("invalid budgets config in %q: %w", fileName, err)
+		}
+	}
+	return export, nil
+}
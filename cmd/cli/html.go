@@ -0,0 +1,272 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+// reportHTML is the template of the HTML report. CSS and JS are inlined, so the report works offline
+//
+//go:embed report.html.tmpl
+var reportHTML string
+
+var reportHTMLTemplate = template.Must(template.New("report").Parse(reportHTML))
+
+// htmlCell is a sortable table cell, colored by the level of its budget verdict
+type htmlCell struct {
+	Text string
+	// Sort is the raw value the column is sorted by
+	Sort float64
+	// Level is "ok", "warn" or "fail". Empty if the metric has no budget
+	Level string
+}
+
+// htmlElement is a selector reported by a metric, e.g. the LCP element
+type htmlElement struct {
+	Metric   string
+	Selector string
+	Snippet  string
+}
+
+type htmlPayload struct {
+	Size string
+	Url  string
+}
+
+// htmlRequest is a bar of the network waterfall. Left and Width are in percent of the last finished request
+type htmlRequest struct {
+	Url      string
+	Start    string
+	Duration string
+	Size     string
+	Left     float64
+	Width    float64
+}
+
+// htmlRow is a performance report with its verdicts and the details shown when the row is expanded
+type htmlRow struct {
+	Level    string
+	Path     string
+	Device   string
+	Country  string
+	Score    htmlCell
+	Cells    []htmlCell
+	Tiers    []htmlCell
+	Notes    []string
+	Elements []htmlElement
+	Payloads []htmlPayload
+	Requests []htmlRequest
+	// PayloadsTotal is the total size of the big payloads
+	PayloadsTotal string
+	WebUrl        string
+}
+
+type htmlPathScore struct {
+	Path  string
+	Score string
+}
+
+// htmlReport is the data the HTML report template is rendered with
+type htmlReport struct {
+	Title          string
+	Generated      string
+	Level          string
+	Verdict        string
+	Summary        string
+	AppliedBudgets string
+	ScoreWeighting string
+	Columns        []string
+	HasTiers       bool
+	Rows           []htmlRow
+	PathScores     []htmlPathScore
+	WebReports     []string
+}
+
+// DetailColumns is the number of columns the expanded details span, every column after the level
+func (r htmlReport) DetailColumns() int {
+	columns := 4 + len(r.Columns)
+	if r.HasTiers {
+		columns++
+	}
+	return columns
+}
+
+// newHTMLReport judges the performance reports of the exports against the rules, like the table
+func newHTMLReport(rules budget.Rules, exports []resultExport, levels rowLevels, expired int, policy failPolicy, appliedBudgets string) htmlReport {
+	report := htmlReport{
+		Title:          "VitalFrog performance report",
+		Generated:      time.Now().UTC().Format(time.RFC3339),
+		AppliedBudgets: appliedBudgets,
+		ScoreWeighting: rules.Scoring().Version,
+		HasTiers:       rules.Local() != nil && len(rules.Local().Tiers) > 0,
+	}
+	if len(exports) > 0 && exports[0].Metadata.Config.Target.Host != "" {
+		report.Title = fmt.Sprintf("%s of %s", report.Title, exports[0].Metadata.Config.Target.Host)
+	}
+
+	//
+	// Verdict header
+	switch {
+	case policy.fails(levels, expired):
+		report.Level, report.Verdict = budget.LevelFail.String(), "Performance budgets failed"
+	case levels.atLeast(budget.LevelWarn) > 0:
+		report.Level, report.Verdict = budget.LevelWarn.String(), "Performance budgets passed with warnings"
+	default:
+		report.Level, report.Verdict = budget.LevelOk.String(), "Performance budgets passed"
+	}
+	report.Summary = fmt.Sprintf("%d rows: %d failed, %d warnings, %d passed", levels.total(),
+		levels[budget.LevelFail], levels[budget.LevelWarn], levels[budget.LevelOk])
+	if expired > 0 {
+		report.Summary += fmt.Sprintf(", %d expired suppressions", expired)
+	}
+
+	//
+	// Columns of the budgeted metrics. The score has its own column
+	metrics := make([]vfrogapi.PerformanceBudgetMetric, 0)
+	for _, metric := range rules.Metrics() {
+		if metric == budget.MetricPerformanceScore {
+			continue
+		}
+		metrics = append(metrics, metric)
+		title := string(metric)
+		if info, ok := budget.Info(metric); ok {
+			title = info.Short
+		}
+		report.Columns = append(report.Columns, title)
+	}
+
+	tested := make([]vfrogapi.PerformanceReport, 0)
+	for _, export := range exports {
+		if export.Metadata.Uuid != "" {
+			report.WebReports = append(report.WebReports, reportWebUrl(export.Metadata.Uuid))
+		}
+		for _, performanceReport := range export.Data {
+			tested = append(tested, performanceReport)
+			row := newHTMLRow(rules, performanceReport, metrics)
+			if group := export.Groups[performanceReport.Id]; group != "" {
+				row.Path = fmt.Sprintf("[%s] %s", group, row.Path)
+			}
+			if export.Metadata.Uuid != "" {
+				row.WebUrl = reportWebUrl(export.Metadata.Uuid)
+			}
+			report.Rows = append(report.Rows, row)
+		}
+	}
+
+	scores := rules.Scoring().PathScores(tested)
+	for path, score := range scores {
+		report.PathScores = append(report.PathScores, htmlPathScore{Path: path, Score: fmt.Sprintf("%.0f", score)})
+	}
+	sort.Slice(report.PathScores, func(i, j int) bool {
+		return report.PathScores[i].Path < report.PathScores[j].Path
+	})
+	return report
+}
+
+// newHTMLRow evaluates a performance report and collects its elements, big payloads and network requests
+func newHTMLRow(rules budget.Rules, report vfrogapi.PerformanceReport, metrics []vfrogapi.PerformanceBudgetMetric) htmlRow {
+	result := rules.Evaluate(report)
+	row := htmlRow{
+		Level:   result.Level().String(),
+		Path:    report.Path,
+		Device:  string(report.Device.Name),
+		Country: report.Country.Code,
+	}
+
+	cell := func(metric vfrogapi.PerformanceBudgetMetric) htmlCell {
+		value, _ := rules.MetricValue(report, metric)
+		if verdict, ok := result.Verdict(metric); ok {
+			return htmlCell{Text: verdict.String(), Sort: value.Amount, Level: verdict.Level.String()}
+		}
+		return htmlCell{Text: value.String(), Sort: value.Amount}
+	}
+	row.Score = cell(budget.MetricPerformanceScore)
+	for _, metric := range metrics {
+		row.Cells = append(row.Cells, cell(metric))
+	}
+	for _, v := range result.TierVerdicts {
+		row.Tiers = append(row.Tiers, htmlCell{Text: v.String(), Level: v.Level.String()})
+	}
+	for _, note := range localRuleNotes(result) {
+		row.Notes = append(row.Notes, stripColor(note))
+	}
+
+	//
+	// Elements, big payloads and the network waterfall
+	addElements := func(metric string, elements ...vfrogapi.Element) {
+		for _, el := range elements {
+			if el.Selector == "" {
+				continue
+			}
+			element := htmlElement{Metric: metric, Selector: el.Selector}
+			if el.Snippet != nil {
+				element.Snippet = *el.Snippet
+			}
+			row.Elements = append(row.Elements, element)
+		}
+	}
+	addElements("LCP", report.LargestContentfulPaint.Element)
+	if report.CumulativeLayoutShift.Elements != nil {
+		addElements("CLS", *report.CumulativeLayoutShift.Elements...)
+	}
+	if report.FirstContentfulPaint.Elements != nil {
+		addElements("FCP", *report.FirstContentfulPaint.Elements...)
+	}
+
+	if len(report.BigPayloads.Payloads) > 0 {
+		row.PayloadsTotal = budget.Value{Amount: float64(report.BigPayloads.TotalBytes), Unit: budget.UnitBytes}.String()
+	}
+	for _, p := range report.BigPayloads.Payloads {
+		size := budget.Value{Amount: float64(p.TotalBytes), Unit: budget.UnitBytes}
+		row.Payloads = append(row.Payloads, htmlPayload{Size: size.String(), Url: p.Url})
+	}
+
+	requests := append([]vfrogapi.NetworkRequest(nil), report.NetworkRequests...)
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].StartTimeMs < requests[j].StartTimeMs
+	})
+	var end int32
+	for _, r := range requests {
+		if r.StartTimeMs+r.LoadTimeMs > end {
+			end = r.StartTimeMs + r.LoadTimeMs
+		}
+	}
+	for _, r := range requests {
+		request := htmlRequest{
+			Url:      r.Url,
+			Start:    budget.Value{Amount: float64(r.StartTimeMs), Unit: budget.UnitMilliseconds}.String(),
+			Duration: budget.Value{Amount: float64(r.LoadTimeMs), Unit: budget.UnitMilliseconds}.String(),
+			Size:     budget.Value{Amount: float64(r.SizeByte), Unit: budget.UnitBytes}.String(),
+		}
+		if end > 0 {
+			request.Left = float64(r.StartTimeMs) / float64(end) * 100
+			request.Width = float64(r.LoadTimeMs) / float64(end) * 100
+		}
+		row.Requests = append(row.Requests, request)
+	}
+	return row
+}
+
+// writeHTMLReport writes the self-contained HTML report of the exports
+func writeHTMLReport(fileName string, rules budget.Rules, exports []resultExport, levels rowLevels, expired int, policy failPolicy, appliedBudgets string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("could not create %q: %w", fileName, err)
+	}
+	defer f.Close()
+
+	report := newHTMLReport(rules, exports, levels, expired, policy, appliedBudgets)
+	if err := reportHTMLTemplate.Execute(f, report); err != nil {
+		return fmt.Errorf("could not render HTML report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close %q: %w", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"os"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root of a JUnit XML report, as understood by Jenkins, GitLab and Azure
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the testcases of a single performance report (path, device and country)
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+// add adds the testcase to the suite. Failures carry the details and the report url, warnings are skipped with them
+func (s *junitTestSuite) add(name string, level budget.Level, message, details string) {
+	testCase := junitTestCase{Name: name, ClassName: s.Name}
+	switch level {
+	case budget.LevelFail:
+		testCase.Failure = &junitMessage{Message: message, Type: "budget", Text: details}
+		s.Failures++
+	case budget.LevelWarn:
+		testCase.Skipped = &junitMessage{Message: fmt.Sprintf("warning: %s", message), Text: details}
+		s.Skipped++
+	default:
+		testCase.SystemOut = message
+	}
+	s.Tests++
+	s.Cases = append(s.Cases, testCase)
+}
+
+// newJUnitReport judges the performance reports of the exports against the rules. Every performance report is a
+// testsuite, every budgeted metric, rule, regression and tier a testcase
+func newJUnitReport(rules budget.Rules, exports []resultExport) junitTestSuites {
+	suites := junitTestSuites{Name: "VitalFrog performance budgets"}
+	for _, export := range exports {
+		webUrl := reportWebUrl(export.Metadata.Uuid)
+		timestamp := ""
+		if export.Metadata.Finished != nil {
+			timestamp = export.Metadata.Finished.Format(time.RFC3339)
+		}
+
+		for _, report := range export.Data {
+			result := rules.Evaluate(report)
+			name := fmt.Sprintf("%s %s %s", report.Path, report.Device.Name, report.Country.Code)
+			if group := export.Groups[report.Id]; group != "" {
+				name = fmt.Sprintf("[%s] %s", group, name)
+			}
+			score, _ := rules.MetricValue(report, budget.MetricPerformanceScore)
+			suite := junitTestSuite{
+				Name:      name,
+				Timestamp: timestamp,
+				Properties: []junitProperty{
+					{Name: "report_url", Value: webUrl},
+					{Name: "performance_score", Value: score.String()},
+					{Name: "score_weighting", Value: rules.Scoring().Version},
+				},
+			}
+			details := func(lines ...string) string {
+				return strings.Join(append(lines, fmt.Sprintf("Report %s", webUrl)), "\n")
+			}
+
+			for _, v := range result.Verdicts {
+				caseName := string(v.Metric)
+				if info, ok := budget.Info(v.Metric); ok {
+					caseName = fmt.Sprintf("%s (%s)", info.Name, info.Short)
+				}
+				lines := []string{
+					fmt.Sprintf("Value %s", v.Value),
+					fmt.Sprintf("Warning threshold %s, error threshold %s (mode %s)", v.Warning, v.Error, v.Mode),
+					fmt.Sprintf("Budget of %s", v.Rule),
+				}
+				if v.Suppressed != nil {
+					lines = append(lines, fmt.Sprintf("Suppressed %s", v.Suppressed))
+				}
+				suite.add(caseName, v.Level, v.String(), details(lines...))
+			}
+			for _, v := range result.RuleVerdicts {
+				suite.add(fmt.Sprintf("rule %s", v.Name), v.Level, v.String(), details(fmt.Sprintf("Expression %s", v.Expr)))
+			}
+			for _, v := range result.RegressionVerdicts {
+				suite.add(fmt.Sprintf("regression %s", v.Metric), v.Level, v.String(), details())
+			}
+			for _, v := range result.TierVerdicts {
+				lines := make([]string, 0, len(v.Verdicts))
+				for _, verdict := range v.Verdicts {
+					lines = append(lines, fmt.Sprintf("%s %s: %s", verdict.Metric, verdict.Level, verdict))
+				}
+				suite.add(fmt.Sprintf("tier %s (%s)", v.Tier, v.Effect), v.EffectiveLevel(), v.String(), details(lines...))
+			}
+
+			suites.Tests += suite.Tests
+			suites.Failures += suite.Failures
+			suites.Skipped += suite.Skipped
+			suites.Suites = append(suites.Suites, suite)
+		}
+	}
+	return suites
+}
+
+// writeJUnit writes the JUnit XML report of the exports
+func writeJUnit(fileName string, rules budget.Rules, exports []resultExport) error {
+	raw, err := xml.MarshalIndent(newJUnitReport(rules, exports), "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not xml marshal junit report: %w", err)
+	}
+	err = os.WriteFile(fileName, append([]byte(xml.Header), raw...), 0o644)
+	if err != nil {
+		return fmt.Errorf("could not write %q: %w", fileName, err)
+	}
+	return nil
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/score"
 	"github.com/fatih/color"
 	log "github.com/sirupsen/logrus"
-	"github.com/vitalfrog/termtable"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -17,110 +21,240 @@ func main() {
 	fmt.Println(vitalFrogHeaderText)
 
 	//
-	// Parse config struct
-	cfg, err := parseEnvironmentToConfig()
+	// Config and API errors exit with exitError, to tell them apart from budget failures
+	log.StandardLogger().ExitFunc = func(int) {
+		os.Exit(exitError)
+	}
+
+	//
+	// Parse cli and run the selected command
+	cli := parseCLI()
+	switch cli.command {
+	case "merge":
+		runMerge(cli.Merge)
+	case "budgets test":
+		runBudgetsTest(cli.Budgets.Test)
+	case "budgets suggest":
+		runBudgetsSuggest(cli.Budgets.Suggest)
+	case "budgets import-lighthouse":
+		runBudgetsImportLighthouse(cli.Budgets.ImportLighthouse)
+	case "budgets export-lighthouse":
+		runBudgetsExportLighthouse(cli.Budgets.ExportLighthouse)
+	default:
+		cfg, err := cli.Run.prepare()
+		if err != nil {
+			log.Fatalf(err.Error())
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if cfg.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+			defer cancel()
+		}
+		runReport(ctx, cfg)
+	}
+}
+
+func runReport(ctx context.Context, cfg *config) {
+	//
+	// Only test the paths of this shard, if running in a parallel CI job
+	shardedPaths, err := cfg.shardPaths(cfg.TargetPaths)
 	if err != nil {
-		log.Fatalf(err.Error())
+		log.Fatalf("could not shardPaths: %s", err)
 	}
+	cfg.TargetPaths = shardedPaths
 
 	//
-	// Create new report
-	vfAPI := vfrogapi.New(cfg.APIBaseUrl, cfg.APIToken)
-	reportConfig := cfg.ToReportConfig()
+	// Sample paths, if only a subset should be tested per run
+	sampledPaths, commitSample, err := cfg.samplePaths(cfg.TargetPaths)
+	if err != nil {
+		log.Fatalf("could not samplePaths: %s", err)
+	}
+	cfg.TargetPaths = sampledPaths
 
-	metadata, err := vfAPI.CreateReport(reportConfig)
+	//
+	// Split paths into path groups with their own http settings. Every group gets its own report
+	groups, err := cfg.groupConfigs()
 	if err != nil {
-		log.Fatalf("Could not CreateReport: %s", err)
+		log.Fatalf("could not groupConfigs: %s", err)
+	}
+
+	//
+	// Create new reports
+	vfAPI := vfrogapi.New(cfg.APIBaseUrl, cfg.APIToken)
+	reports := make([]createdReport, 0, len(groups))
+	for _, group := range groups {
+		reportConfig := group.cfg.ToReportConfig()
+
+		metadata, err := vfAPI.CreateReport(reportConfig)
+		if err != nil {
+			log.Fatalf("Could not CreateReport: %s", err)
+		}
+
+		if metadata == nil {
+			log.Fatalf("Did get nil metadata as response from VitalFrog API. This is not valid.")
+		}
+		reports = append(reports, createdReport{group: group.name, cfg: group.cfg, metadata: metadata})
 	}
 
-	if metadata == nil {
-		log.Fatalf("Did get nil metadata as response from VitalFrog API. This is not valid.")
+	if err := commitSample(); err != nil {
+		log.Errorf("could not persist sampling state: %s", err)
 	}
 
 	//
 	// Load reports performance budgets for later coloring of the cli
-	var performanceBudgets *vfrogapi.PerformanceBudgets
-	if metadata.Config.PerformanceBudgetsId != nil {
-		performanceBudgets, err = vfAPI.GetPerformanceBudgets(*metadata.Config.PerformanceBudgetsId)
+	// All reports share the same config apart from the paths and http settings, so the budgets are the same as well
+	performanceBudgets, appliedBudgets, err := loadPerformanceBudgets(cfg, vfAPI, reports[0].metadata.Config.PerformanceBudgetsId)
+	if err != nil {
+		log.Fatalf("could not loadPerformanceBudgets: %s", err)
+	}
+
+	//
+	// Layer the local budgets file over the reports performance budgets
+	var localBudgets *budget.Config
+	if cfg.BudgetsFile != "" {
+		localBudgets, err = budget.ReadConfig(cfg.BudgetsFile)
+		if err != nil {
+			log.Fatalf("could not read BUDGETS_FILE: %s", err)
+		}
+		err = resolveTiers(vfAPI, localBudgets)
+		if err != nil {
+			log.Fatalf("could not resolveTiers: %s", err)
+		}
+		appliedBudgets = fmt.Sprintf("%s, layered with BUDGETS_FILE %s", appliedBudgets, cfg.BudgetsFile)
+	}
+	rules := budget.NewRules(performanceBudgets, localBudgets)
+
+	scoring, err := score.Lookup(cfg.ScoreWeighting)
+	if err != nil {
+		log.Fatalf("could not Lookup score weighting: %s", err)
+	}
+	rules = rules.WithScoring(scoring)
+
+	//
+	// Downgrade known violations to warnings until their suppression expires
+	if cfg.SuppressionsFile != "" {
+		suppressions, err := budget.ReadSuppressions(cfg.SuppressionsFile)
 		if err != nil {
-			log.Fatalf("could not GetPerformanceBudgets: %s", err)
+			log.Fatalf("could not read SUPPRESSIONS_FILE: %s", err)
 		}
+		rules = rules.WithSuppressions(suppressions, time.Now())
+	}
+
+	//
+	// Load the baseline to compare regressions against
+	baseline, err := loadBaseline(cfg, vfAPI)
+	if err != nil {
+		log.Fatalf("could not loadBaseline: %s", err)
+	}
+	if baseline != nil {
+		rules = rules.WithBaseline(baseline)
 	}
 
 	//
 	// Print basic info
 	fmt.Print("\n----------\n")
-	fmt.Printf("\nCreated at %s\n", metadata.Created.Format(time.RFC822))
-	fmt.Printf("Costs %d tokens\n", metadata.Cost)
-	fmt.Printf("Report web url https://app.vitalfrog.com/report/%s\n", metadata.Uuid)
+	for _, r := range reports {
+		if r.group != "" {
+			fmt.Printf("\nPath group %q\n", r.group)
+		}
+		fmt.Printf("\nCreated at %s\n", r.metadata.Created.Format(time.RFC822))
+		fmt.Printf("Costs %d tokens\n", r.metadata.Cost)
+		fmt.Printf("Report web url %s\n", reportWebUrl(r.metadata.Uuid))
+	}
 	fmt.Print("\n----------\n")
+	printBudgetProblems(rules)
 
 	//
 	// Write performance report table to cli
 	// Only write table if we have sync report
-	if !cfg.RunAsync {
-		tt := termtable.New(os.Stdout, " | ")
-		tt.WriteHeader([]termtable.HeaderField{
-			{
-				Field: termtable.NewStringField("Path"),
-			},
-			{
-				Field: termtable.NewStringField("Country"),
-				Width: termtable.IntPointer(4),
-			},
-			{
-				Field: termtable.NewStringField("Device"),
-				Width: termtable.IntPointer(10),
-			},
-			{
-				Field: termtable.NewStringField("Max First Input Delay"),
-				Width: termtable.IntPointer(10),
-			},
-			{
-				Field: termtable.NewStringField("Server response time"),
-				Width: termtable.IntPointer(10),
-			},
-			{
-				Field: termtable.NewStringField("Time to interactive"),
-				Width: termtable.IntPointer(10),
-			},
-			{
-				Field: termtable.NewStringField("Cumulative Layout Shift"),
-				Width: termtable.IntPointer(40),
-			},
-			{
-				Field: termtable.NewStringField("Largest Contentful Paint"),
-				Width: termtable.IntPointer(40),
-			},
-		})
-		tt.WriteRowDivider('=')
+	if cfg.RunAsync {
+		if expired := printExpiredSuppressions(rules); expired > 0 {
+			defer printBudgetVerdict(rowLevels{}, expired, cfg.FailPolicy, resultFiles{})
+		}
+	} else {
+		tt := newReportTable(os.Stdout, rules)
+		var out *resultOutput
+		if cfg.Output != "none" {
+			out, err = newResultOutput(cfg.Output, cfg.OutputFile, rules)
+			if err != nil {
+				log.Fatalf("could not newResultOutput: %s", err)
+			}
+		}
 
 		//
 		// Get budgets from channel and write them as table rows
-		// If highestBudgetLevel is 2, return os.Exit(1). To trigger CI failure
-		highestBudgetLevel, err := writeBudgetRows(tt, vfAPI, metadata.Uuid, performanceBudgets)
-		if err != nil {
-			log.Errorf("could not writeBudgetRows: %s", err)
-		}
-		defer func(highestBudgetLevel int) {
-			switch highestBudgetLevel {
-			case 0:
-				color.New(color.FgGreen).Print("All metrics are in a good shape. Nothing to do.")
-			case 1:
-				color.New(color.FgYellow).Print("You have a few metrics which you should look at as they are in the warning state. Please check above table.")
-			case 2:
-				color.New(color.FgRed).Print("Got at least one metric which is not within an acceptable performance budget. Please check above table. (Marked with '✖')")
-				os.Exit(1)
+		// An API error, timeout or cancellation aborts the run with its own exit code, even if some rows are written
+		var runErr error
+		exports := make([]resultExport, 0, len(reports))
+		for _, r := range reports {
+			if out != nil {
+				out.writeMetadata(*r.metadata)
+				tt.onResult = out.rowWriter(r.metadata.Uuid)
+			}
+			var report *vfrogapi.Report
+			if cfg.Repeat > 1 {
+				report, runErr = writeRepeatedRows(ctx, tt, vfAPI, cfg, r)
+			} else {
+				report, runErr = writeBudgetRows(ctx, tt, vfAPI, r.metadata.Uuid, r.group)
+			}
+			if runErr != nil {
+				runErr = fmt.Errorf("could not write rows of report %q: %w", r.metadata.Uuid, runErr)
+				break
+			}
+			exports = append(exports, newResultExport(*report, r.group, rules))
+		}
+		tt.writeTierSummary()
+		tested := make([]vfrogapi.PerformanceReport, 0)
+		for _, export := range exports {
+			tested = append(tested, export.Data...)
+		}
+		writePathScores(os.Stdout, tested, rules.Scoring())
+		if cfg.CWVAssessment {
+			writeCWVAssessment(os.Stdout, tested)
+		}
+		expired := printExpiredSuppressions(rules)
+		var written resultFiles
+		if out != nil {
+			written.check(out.close(tested, tt.levels, expired, cfg.FailPolicy, appliedBudgets), "could not write OUTPUT_FILE")
+		}
+
+		//
+		// Save results for later merging of shards
+		if cfg.ExportFile != "" && runErr == nil {
+			merged, err := mergeResultExports(exports)
+			if err == nil {
+				err = writeResultExport(cfg.ExportFile, merged)
 			}
-		}(highestBudgetLevel)
+			written.check(err, "could not write EXPORT_FILE")
+		}
+		if runErr == nil {
+			written.check(cfg.Markdown.write(rules, exports, tt.levels, expired, cfg.FailPolicy, appliedBudgets), "could not write Markdown summary")
+		}
+		if cfg.JUnitFile != "" && runErr == nil {
+			written.check(writeJUnit(cfg.JUnitFile, rules, exports), "could not writeJUnit")
+		}
+		if cfg.HTMLFile != "" && runErr == nil {
+			written.check(writeHTMLReport(cfg.HTMLFile, rules, exports, tt.levels, expired, cfg.FailPolicy, appliedBudgets), "could not writeHTMLReport")
+		}
+		if runErr != nil {
+			defer exitWithError(runErr)
+		} else {
+			defer printBudgetVerdict(tt.levels, expired, cfg.FailPolicy, written)
+		}
 	}
 
 	//
 	// Write report summary footer
-	if jsonConfig, err := json.Marshal(metadata.Config); err == nil {
-		fmt.Printf("\n----------\n\nConfig:\n%s\n", string(jsonConfig))
-	} else {
-		fmt.Printf("\n----------\n\nConfig:\n%+v\n", metadata.Config)
+	fmt.Print("\n----------\n")
+	for _, r := range reports {
+		if jsonConfig, err := json.Marshal(r.metadata.Config); err == nil {
+			fmt.Printf("\nConfig:\n%s\n", string(jsonConfig))
+		} else {
+			fmt.Printf("\nConfig:\n%+v\n", r.metadata.Config)
+		}
 	}
 
 	if performanceBudgets != nil {
@@ -131,206 +265,360 @@ func main() {
 		}
 	}
 
+	if localBudgets != nil {
+		if jsonBudgets, err := json.Marshal(localBudgets); err == nil {
+			fmt.Printf("\nLocal Budgets:\n%s\n\n----------\n", string(jsonBudgets))
+		}
+	}
+	fmt.Printf("\nApplied budgets: %s\n", appliedBudgets)
+
 }
 
-func writeBudgetRows(tt *termtable.TermTable,
-	vfAPI vfrogapi.Client,
-	uuid string,
-	performanceBudgets *vfrogapi.PerformanceBudgets) (int, error) {
-	highestBudgetLevel := 0
-	seenReports := map[int32]struct{}{}
-	errCount := 0
-	for {
-		time.Sleep(time.Duration(rand.Intn(5000-1000)+1000) * time.Millisecond)
-		report, err := vfAPI.GetReport(uuid)
+// createdReport is a report created for a path group. Group is empty if no path groups are configured
+type createdReport struct {
+	group    string
+	cfg      config
+	metadata *vfrogapi.ReportMetadata
+}
+
+// loadPerformanceBudgets returns the performance budgets the reports are judged against, and describes which ones they
+// are. A preset replaces the budgets of the report. If the report has no performance budgets, BUDGETS_FALLBACK decides.
+// Nil budgets only report the metrics. The reports are already created and paid for, so a failing lookup of the account
+// default only reports the metrics instead of failing the run.
+func loadPerformanceBudgets(cfg *config, vfAPI vfrogapi.Client, performanceBudgetsId *int32) (*vfrogapi.PerformanceBudgets, string, error) {
+	switch {
+	case cfg.BudgetsPreset != "":
+		preset, _ := budget.Preset(cfg.BudgetsPreset)
+		return preset, fmt.Sprintf("preset %s (BUDGETS_PRESET)", cfg.BudgetsPreset), nil
+	case performanceBudgetsId != nil:
+		performanceBudgets, err := vfAPI.GetPerformanceBudgets(*performanceBudgetsId)
 		if err != nil {
-			errCount++
-			if errCount <= 5 {
-				log.Errorf("could not GetReport: %w", err)
-				continue
+			return nil, "", fmt.Errorf("could not GetPerformanceBudgets: %w", err)
+		}
+		return performanceBudgets, fmt.Sprintf("performance budgets %d %q", performanceBudgets.Id, performanceBudgets.Description), nil
+	}
+
+	switch cfg.BudgetsFallback {
+	case "account-default":
+		list, err := vfAPI.ListPerformanceBudgets()
+		if err != nil {
+			log.Warnf("could not ListPerformanceBudgets, only reporting the metrics: %s", err)
+			return nil, "none, the default performance budgets of the account could not be loaded. Only reporting the metrics", nil
+		}
+		for k := range list {
+			if list[k].Default {
+				return &list[k], fmt.Sprintf("default performance budgets %d %q of the account (no PERFORMANCE_BUDGETS_ID)", list[k].Id, list[k].Description), nil
 			}
-			return -1, fmt.Errorf("could not GetReport: %w", err)
 		}
+		return nil, "none, the account has no default performance budgets. Only reporting the metrics", nil
+	case "preset":
+		preset, _ := budget.Preset(cfg.BudgetsFallbackPreset)
+		return preset, fmt.Sprintf("preset %s (BUDGETS_FALLBACK, no PERFORMANCE_BUDGETS_ID)", cfg.BudgetsFallbackPreset), nil
+	}
+	return nil, "none, only reporting the metrics (BUDGETS_FALLBACK=report-only)", nil
+}
 
-		for _, report := range report.Data {
-			if _, seen := seenReports[report.Id]; seen {
-				continue
+// resolveTiers fetches the server side budgets of the tiers referencing them by id. Exports keep the fetched budgets,
+// so saved reports can be judged against the tiers offline
+func resolveTiers(vfAPI vfrogapi.Client, cfg *budget.Config) error {
+	for k, tier := range cfg.Tiers {
+		if tier.PerformanceBudgetsId == nil || tier.Resolved() {
+			continue
+		}
+		performanceBudgets, err := vfAPI.GetPerformanceBudgets(*tier.PerformanceBudgetsId)
+		if err != nil {
+			return fmt.Errorf("could not GetPerformanceBudgets of tier %q: %w", tier.Name, err)
+		}
+		cfg.Tiers[k].Budgets = performanceBudgets.Budgets
+	}
+	return nil
+}
+
+// loadBaseline loads the performance reports of BASELINE_FILE, BASELINE_REPORT_UUID or the latest report of
+// BASELINE_VERSION. Returns nil if none is configured
+func loadBaseline(cfg *config, vfAPI vfrogapi.Client) (budget.Baseline, error) {
+	uuid := cfg.BaselineReportUuid
+	if cfg.BaselineVersion != "" {
+		var err error
+		uuid, err = latestReportOfVersion(vfAPI, cfg.ComponentName, cfg.BaselineVersion)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Using report %q of version %q as baseline", uuid, cfg.BaselineVersion)
+	}
+
+	switch {
+	case cfg.BaselineFile != "":
+		export, err := readResultExport(cfg.BaselineFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not readResultExport: %w", err)
+		}
+		return budget.NewBaseline(export.Data), nil
+	case uuid != "":
+		report, err := vfAPI.GetReport(uuid)
+		if err != nil {
+			return nil, fmt.Errorf("could not GetReport: %w", err)
+		}
+		if report.Metadata.Finished == nil {
+			return nil, fmt.Errorf("baseline report %q is not finished yet", uuid)
+		}
+		if c := report.Metadata.Config.Component; cfg.ComponentName != "" && (c == nil || *c != cfg.ComponentName) {
+			log.Warnf("Baseline report %q is not of component %q", uuid, cfg.ComponentName)
+		}
+		return budget.NewBaseline(report.Data), nil
+	}
+	return nil, nil
+}
+
+// latestReportOfVersion returns the uuid of the newest finished report of the component on the version
+func latestReportOfVersion(vfAPI vfrogapi.Client, component, version string) (string, error) {
+	reports, err := vfAPI.ListReports(component, version)
+	if err != nil {
+		return "", fmt.Errorf("could not ListReports: %w", err)
+	}
+	return latestFinishedReport(reports, component, version)
+}
+
+// latestFinishedReport returns the uuid of the newest finished report of the component on the version. The reports are
+// filtered again, in case the server ignores the filters
+func latestFinishedReport(reports []vfrogapi.ReportMetadata, component, version string) (string, error) {
+	var latest *vfrogapi.ReportMetadata
+	unfinished := 0
+	for i, r := range reports {
+		if r.Config.Version == nil || *r.Config.Version != version {
+			continue
+		}
+		if c := r.Config.Component; c == nil || *c != component {
+			continue
+		}
+		if r.Finished == nil {
+			unfinished++
+			continue
+		}
+		if latest == nil || r.Finished.After(*latest.Finished) {
+			latest = &reports[i]
+		}
+	}
+	switch {
+	case latest != nil:
+		return latest.Uuid, nil
+	case unfinished > 0:
+		return "", fmt.Errorf("none of the %d reports of component %q on version %q is finished yet", unfinished, component, version)
+	}
+	return "", fmt.Errorf("version %q of component %q not found, there is no report of it", version, component)
+}
+
+// printBudgetProblems warns about inconsistent budgets, e.g. a warning threshold which is never crossed before the error
+// threshold. They are only warned about, as the server side budgets are not under control of every user
+func printBudgetProblems(rules budget.Rules) {
+	problems := rules.Validate()
+	if len(problems) == 0 {
+		return
+	}
+	yellow.Print("\nThe budgets are inconsistent. Results are judged against them anyway:\n")
+	for _, p := range problems {
+		yellow.Printf("   ⚠ %s\n", p)
+	}
+	fmt.Print("\n----------\n")
+}
+
+// printExpiredSuppressions lists the expired suppressions and returns their number. They fail the run, so they can not
+// quietly live forever
+func printExpiredSuppressions(rules budget.Rules) int {
+	expired := rules.ExpiredSuppressions()
+	if len(expired) == 0 {
+		return 0
+	}
+	red.Print("\nExpired suppressions. Fix the violations or extend the suppressions:\n")
+	for _, s := range expired {
+		red.Printf("   ✖ %s\n", s)
+	}
+	return len(expired)
+}
+
+// reportWebUrl returns the url of the report in the VitalFrog web app
+func reportWebUrl(uuid string) string {
+	return fmt.Sprintf("https://app.vitalfrog.com/report/%s", uuid)
+}
+
+// printBudgetVerdict prints the overall verdict. If a result file could not be written, it exits with exitError. If the
+// fail policy is met, it exits with exitBudgetFailure to trigger a CI failure
+func printBudgetVerdict(levels rowLevels, expiredSuppressions int, policy failPolicy, written resultFiles) {
+	if levels.total() > 0 {
+		switch levels.highest() {
+		case budget.LevelOk:
+			color.New(color.FgGreen).Print("All metrics are in a good shape. Nothing to do.")
+		case budget.LevelWarn:
+			color.New(color.FgYellow).Print("You have a few metrics which you should look at as they are in the warning state. Please check above table.")
+		case budget.LevelFail:
+			color.New(color.FgRed).Print("Got at least one metric which is not within an acceptable performance budget. Please check above table. (Marked with '✖')")
+		}
+	}
+	if expiredSuppressions > 0 {
+		color.New(color.FgRed).Printf("\n%d suppressions are expired.", expiredSuppressions)
+	}
+	if err := written.err(); err != nil {
+		fmt.Println()
+		exitWithError(err)
+	}
+
+	if policy.fails(levels, expiredSuppressions) {
+		os.Exit(exitBudgetFailure)
+	}
+	if failing := levels.atLeast(policy.level()); policy.FailOn != "never" && failing > 0 {
+		color.New(color.FgYellow).Printf("\nNot failing the run: %d of %d rows reached FAIL_ON=%s, which is below FAIL_MIN_ROWS=%d or FAIL_MIN_PERCENT=%g.", failing, levels.total(), policy.FailOn, policy.FailMinRows, policy.FailMinPercent)
+	}
+}
+
+// writeBudgetRows polls the report until it is finished and writes every new performance report as table row.
+// Returns the finished report.
+func writeBudgetRows(ctx context.Context,
+	tt *reportTable,
+	vfAPI vfrogapi.Client,
+	uuid string,
+	group string) (*vfrogapi.Report, error) {
+	return pollReport(ctx, vfAPI, uuid, func(performanceReport vfrogapi.PerformanceReport) {
+		tt.writeRow(group, performanceReport)
+	})
+}
+
+// writeRepeatedRows awaits the first run of the report and creates the further runs of REPEAT one after the other.
+// With REPEAT_ONLY_FAILING only the path, device and country combinations with failing budgets are repeated.
+// Afterwards the runs are aggregated per path, device and country and written as table rows.
+// Returns the report holding the aggregated performance reports and the costs of all runs.
+func writeRepeatedRows(ctx context.Context,
+	tt *reportTable,
+	vfAPI vfrogapi.Client,
+	cfg *config,
+	created createdReport) (*vfrogapi.Report, error) {
+	aggregation := budget.Aggregation(cfg.RepeatAggregate)
+	report, err := pollReport(ctx, vfAPI, created.metadata.Uuid, func(vfrogapi.PerformanceReport) {})
+	if err != nil {
+		return nil, fmt.Errorf("could not pollReport: %w", err)
+	}
+
+	runs := report.Data
+	for run := 2; run <= cfg.Repeat; run++ {
+		runCfgs := []config{created.cfg}
+		if cfg.RepeatOnlyFailing {
+			runCfgs, err = failingCombos(tt.rules, runs, aggregation, created.cfg)
+			if err != nil {
+				return nil, fmt.Errorf("could not failingCombos: %w", err)
 			}
-			seenReports[report.Id] = struct{}{}
-
-			lcp := fmt.Sprintf("%dms", report.LargestContentfulPaint.ValueMs)
-			fid := fmt.Sprintf("%dms", report.MaxPotentialFidMs)
-			cls := fmt.Sprintf("%f", report.CumulativeLayoutShift.Value)
-			serverResponseTime := fmt.Sprintf("%dms", report.ServerResponseTimeMs)
-			interactive := fmt.Sprintf("%dms", report.InteractiveMs)
-
-			lcpColor := white
-			fidColor := white
-			clsColor := white
-			serverResponseTimeColor := white
-			interactiveColor := white
-
-			for _, budget := range performanceBudgets.Budgets {
-				compare := func(value int32) (*color.Color, int) {
-					return compareValueAgainstBudget(value, budget)
-				}
-				var highestBudget int
-				switch budget.Metric {
-				case vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs:
-					lcpColor, highestBudget = compare(report.LargestContentfulPaint.ValueMs)
-					if highestBudget == 2 {
-						lcp = fmt.Sprintf("✖ %s", lcp)
-					}
-				case vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs:
-					fidColor, highestBudget = compare(report.MaxPotentialFidMs)
-					if highestBudget == 2 {
-						fid = fmt.Sprintf("✖ %s", fid)
-					}
-				case vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:
-					clsColor, highestBudget = compare(int32(report.CumulativeLayoutShift.Value * 100))
-					if highestBudget == 2 {
-						cls = fmt.Sprintf("✖ %s", cls)
-					}
-				case vfrogapi.PerformanceBudgetMetricServerResponseTimeMs:
-					serverResponseTimeColor, highestBudget = compare(report.ServerResponseTimeMs)
-					if highestBudget == 2 {
-						serverResponseTime = fmt.Sprintf("✖ %s", serverResponseTime)
-					}
-				case vfrogapi.PerformanceBudgetMetricInteractiveMs:
-					interactiveColor, highestBudget = compare(report.InteractiveMs)
-					if highestBudget == 2 {
-						interactive = fmt.Sprintf("✖ %s", interactive)
-					}
-				}
-
-				if highestBudget > highestBudgetLevel {
-					highestBudgetLevel = highestBudget
-				}
+			if len(runCfgs) == 0 {
+				log.Infof("No failing paths left to repeat after run %d/%d", run-1, cfg.Repeat)
+				break
 			}
+		}
 
-			tt.WriteRow([]termtable.Field{
-				termtable.NewStringField(report.Path),
-				termtable.NewStringField(report.Country.Code),
-				termtable.NewStringField(string(report.Device.Name)),
-				termtable.NewColorField(fid, fidColor),
-				termtable.NewColorField(serverResponseTime, serverResponseTimeColor),
-				termtable.NewColorField(interactive, interactiveColor),
-				termtable.NewColorField(cls, clsColor),
-				termtable.NewColorField(lcp, lcpColor),
-			})
-
-			lcpSelectorElements := strings.Split(report.LargestContentfulPaint.Element.Selector, ">")
-			for k, v := range lcpSelectorElements {
-				arrow := ">"
-				if k == 0 {
-					arrow = ""
-				}
-				lcpSelectorElements[k] = fmt.Sprintf("%s%s%s", termtable.WhiteSpace(k), arrow, strings.TrimSpace(v))
+		for _, runCfg := range runCfgs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
 			}
-			var clsSelectorElements []string
-
-			if report.CumulativeLayoutShift.Elements != nil {
-				for _, el := range *report.CumulativeLayoutShift.Elements {
-					elements := strings.Split(el.Selector, ">")
-					for k, v := range elements {
-						arrow := ">"
-						if k == 0 {
-							arrow = ""
-						}
-						clsSelectorElements = append(clsSelectorElements, fmt.Sprintf("%s%s%s", termtable.WhiteSpace(k), arrow, strings.TrimSpace(v)))
-					}
-				}
+			metadata, err := vfAPI.CreateReport(runCfg.ToReportConfig())
+			if err != nil {
+				return nil, fmt.Errorf("could not CreateReport: %w", err)
 			}
+			log.Infof("Run %d/%d tests %d paths%s. Costs %d tokens. Report web url %s", run, cfg.Repeat, len(runCfg.TargetPaths),
+				repeatScope(cfg, runCfg), metadata.Cost, reportWebUrl(metadata.Uuid))
 
-			for k := 0; k < maxInt(len(lcpSelectorElements), len(clsSelectorElements)); k++ {
-				switch {
-				case k < len(lcpSelectorElements) && k < len(clsSelectorElements):
-					// Both still have values
-					tt.WriteRow([]termtable.Field{
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewStringField(clsSelectorElements[k]),
-						termtable.NewStringField(lcpSelectorElements[k]),
-					})
-				case k >= len(lcpSelectorElements) && k < len(clsSelectorElements):
-					//CLS still has values
-					tt.WriteRow([]termtable.Field{
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewStringField(clsSelectorElements[k]),
-						termtable.NewEmptyField(),
-					})
-				case k < len(lcpSelectorElements) && k >= len(clsSelectorElements):
-					// LCP still have values
-					tt.WriteRow([]termtable.Field{
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewEmptyField(),
-						termtable.NewStringField(lcpSelectorElements[k]),
-					})
-				}
+			repeated, err := pollReport(ctx, vfAPI, metadata.Uuid, func(vfrogapi.PerformanceReport) {})
+			if err != nil {
+				return nil, fmt.Errorf("could not pollReport: %w", err)
 			}
+			runs = append(runs, repeated.Data...)
+			report.Metadata.Cost += repeated.Metadata.Cost
+		}
+	}
+
+	aggregated, err := budget.Aggregate(runs, aggregation)
+	if err != nil {
+		return nil, fmt.Errorf("could not Aggregate: %w", err)
+	}
+	report.Data = make([]vfrogapi.PerformanceReport, 0, len(aggregated))
+	for _, a := range aggregated {
+		tt.writeAggregatedRow(created.group, a)
+		report.Data = append(report.Data, a.Report)
+	}
+	return report, nil
+}
 
-			tt.WriteRowDivider('-')
+// repeatScope describes the device and country a run of REPEAT_ONLY_FAILING is limited to, e.g. " on mobile from DE"
+func repeatScope(cfg *config, runCfg config) string {
+	if !cfg.RepeatOnlyFailing {
+		return ""
+	}
+	return fmt.Sprintf(" on %s from %s", strings.Join(runCfg.Devices, ", "), strings.Join(runCfg.AllowedCountries, ", "))
+}
 
+// failingCombos returns a run config per device and country with failing budgets after aggregating the runs. Each
+// tests only the failing paths of its device and country, as a report tests every path on every device and country
+func failingCombos(rules budget.Rules, runs []vfrogapi.PerformanceReport, aggregation budget.Aggregation, base config) ([]config, error) {
+	aggregated, err := budget.Aggregate(runs, aggregation)
+	if err != nil {
+		return nil, fmt.Errorf("could not Aggregate: %w", err)
+	}
+	type deviceCountry struct {
+		device  vfrogapi.DeviceName
+		country string
+	}
+	keys := make([]deviceCountry, 0)
+	paths := map[deviceCountry][]string{}
+	for _, a := range aggregated {
+		if rules.Evaluate(a.Report).Level() != budget.LevelFail {
+			continue
 		}
-		if report.Metadata.Finished != nil {
-			break
+		key := deviceCountry{device: a.Report.Device.Name, country: a.Report.Country.Code}
+		if _, ok := paths[key]; !ok {
+			keys = append(keys, key)
 		}
+		paths[key] = append(paths[key], a.Report.Path)
 	}
-	return highestBudgetLevel, nil
-}
 
-func maxInt(a, v int) int {
-	if a > v {
-		return a
+	cfgs := make([]config, 0, len(keys))
+	for _, key := range keys {
+		runCfg := base
+		runCfg.TargetPaths = paths[key]
+		runCfg.Devices = []string{string(key.device)}
+		runCfg.AllowedCountries = []string{key.country}
+		runCfg.BlockedCountries = nil
+		cfgs = append(cfgs, runCfg)
 	}
-	return v
+	return cfgs, nil
 }
 
-var (
-	green  = color.New(color.FgGreen)
-	yellow = color.New(color.FgYellow)
-	red    = color.New(color.FgRed)
-	white  = color.New(color.FgWhite)
-)
+// pollReport polls the report until it is finished and calls onNew for every performance report not seen before.
+// Gives up after more than 5 failed requests or once the context is done.
+func pollReport(ctx context.Context, vfAPI vfrogapi.Client, uuid string, onNew func(vfrogapi.PerformanceReport)) (*vfrogapi.Report, error) {
+	seenReports := map[int32]struct{}{}
+	errCount := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for report %q: %w", uuid, ctx.Err())
+		case <-time.After(time.Duration(rand.Intn(5000-1000)+1000) * time.Millisecond):
+		}
+		report, err := vfAPI.GetReport(uuid)
+		if err != nil {
+			errCount++
+			if errCount <= 5 {
+				log.Errorf("could not GetReport: %s", err)
+				continue
+			}
+			return nil, fmt.Errorf("could not GetReport: %w", err)
+		}
 
-func compareValueAgainstBudget(value int32, budget vfrogapi.PerformanceBudget) (*color.Color, int) {
-	if budget.Mode == nil || *budget.Mode == vfrogapi.Above {
-		// Check above values
-		switch {
-		case value < budget.Warning:
-			// all alright
-			return green, 0
-		case value >= budget.Warning && value < budget.Error:
-			return yellow, 1
-		default:
-			return red, 2
+		for _, performanceReport := range report.Data {
+			if _, seen := seenReports[performanceReport.Id]; seen {
+				continue
+			}
+			seenReports[performanceReport.Id] = struct{}{}
+			onNew(performanceReport)
+		}
+		if report.Metadata.Finished != nil {
+			return report, nil
 		}
 	}
-	// Check below values
-	switch {
-	case value > budget.Warning:
-		// all alright
-		return green, 0
-	case value <= budget.Warning && value > budget.Error:
-		return yellow, 1
-	default:
-		return red, 2
-	}
-
 }
 
 var vitalFrogHeaderText = color.New(color.FgGreen).SprintFunc()(`                                                                                         
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatestFinishedReport(t *testing.T) {
+	report := func(uuid, component, version string, finished *time.Time) vfrogapi.ReportMetadata {
+		return vfrogapi.ReportMetadata{Uuid: uuid, Finished: finished, Config: vfrogapi.ReportConfig{Component: &component, Version: &version}}
+	}
+	day := func(d int) *time.Time {
+		t := time.Date(2026, 10, d, 10, 0, 0, 0, time.UTC)
+		return &t
+	}
+	tests := []struct {
+		name    string
+		reports []vfrogapi.ReportMetadata
+		want    string
+		wantErr string
+	}{
+		{"newest finished", []vfrogapi.ReportMetadata{report("a", "shop", "1.0", day(1)), report("b", "shop", "1.0", day(3)), report("c", "shop", "1.0", nil)}, "b", ""},
+		{"other versions and components are ignored", []vfrogapi.ReportMetadata{report("a", "shop", "1.0", day(1)), report("b", "shop", "2.0", day(3)), report("c", "cart", "1.0", day(4))}, "a", ""},
+		{"version not found", []vfrogapi.ReportMetadata{report("a", "shop", "2.0", day(1))}, "", "not found"},
+		{"no reports", nil, "", "not found"},
+		{"not finished yet", []vfrogapi.ReportMetadata{report("a", "shop", "1.0", nil)}, "", "is finished yet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := latestFinishedReport(tt.reports, "shop", "1.0")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("latestFinishedReport() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("latestFinishedReport() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
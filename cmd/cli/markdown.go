@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// markdownSummary configures the Markdown summary for pull request comments and job summaries. Shared by the run,
+// merge and budgets test command
+type markdownSummary struct {
+	MarkdownFile string `kong:"env='MARKDOWN_FILE',help='Write a Markdown summary of the results to this file, e.g. to post it as pull request comment'"`
+	StepSummary  bool   `kong:"default='true',negatable,env='STEP_SUMMARY',help='Append the Markdown summary to $GITHUB_STEP_SUMMARY if present'"`
+}
+
+// enabled reports whether a summary is written at all
+func (m markdownSummary) enabled() bool {
+	return m.MarkdownFile != "" || (m.StepSummary && os.Getenv("GITHUB_STEP_SUMMARY") != "")
+}
+
+// write renders the summary into MarkdownFile and appends it to $GITHUB_STEP_SUMMARY
+func (m markdownSummary) write(rules budget.Rules, exports []resultExport, levels rowLevels, expired int, policy failPolicy, appliedBudgets string) error {
+	if !m.enabled() {
+		return nil
+	}
+	summary := renderMarkdown(rules, exports, levels, expired, policy, appliedBudgets)
+
+	if m.MarkdownFile != "" {
+		if err := os.WriteFile(m.MarkdownFile, []byte(summary), 0o644); err != nil {
+			return fmt.Errorf("could not write %q: %w", m.MarkdownFile, err)
+		}
+	}
+	if stepSummary := os.Getenv("GITHUB_STEP_SUMMARY"); m.StepSummary && stepSummary != "" {
+		f, err := os.OpenFile(stepSummary, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("could not open GITHUB_STEP_SUMMARY %q: %w", stepSummary, err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(summary + "\n"); err != nil {
+			return fmt.Errorf("could not append to GITHUB_STEP_SUMMARY %q: %w", stepSummary, err)
+		}
+	}
+	return nil
+}
+
+// levelEmoji is the severity of a budget level in Markdown
+func levelEmoji(level budget.Level) string {
+	switch level {
+	case budget.LevelOk:
+		return "✅"
+	case budget.LevelWarn:
+		return "⚠️"
+	}
+	return "❌"
+}
+
+// renderMarkdown renders a compact summary: a verdict header, a table per performance report with the budgeted
+// metrics, collapsible details with the LCP/CLS selectors and big payloads, and the links to the web reports
+func renderMarkdown(rules budget.Rules, exports []resultExport, levels rowLevels, expired int, policy failPolicy, appliedBudgets string) string {
+	var sb strings.Builder
+
+	//
+	// Verdict header
+	switch {
+	case policy.fails(levels, expired):
+		sb.WriteString("## ❌ VitalFrog performance budgets failed\n\n")
+	case levels.atLeast(budget.LevelWarn) > 0:
+		sb.WriteString("## ⚠️ VitalFrog performance budgets passed with warnings\n\n")
+	default:
+		sb.WriteString("## ✅ VitalFrog performance budgets passed\n\n")
+	}
+	fmt.Fprintf(&sb, "%d rows: %d %s failed, %d %s warnings, %d %s passed", levels.total(),
+		levels[budget.LevelFail], levelEmoji(budget.LevelFail),
+		levels[budget.LevelWarn], levelEmoji(budget.LevelWarn),
+		levels[budget.LevelOk], levelEmoji(budget.LevelOk))
+	if expired > 0 {
+		fmt.Fprintf(&sb, ", %d expired suppressions", expired)
+	}
+	sb.WriteString("\n\n")
+	if appliedBudgets != "" {
+		fmt.Fprintf(&sb, "Applied budgets: %s\n\n", markdownEscape(appliedBudgets))
+	}
+
+	//
+	// Table per performance report with the score and every budgeted metric. The score has its own column
+	metrics := make([]vfrogapi.PerformanceBudgetMetric, 0)
+	for _, metric := range rules.Metrics() {
+		if metric != budget.MetricPerformanceScore {
+			metrics = append(metrics, metric)
+		}
+	}
+	hasTiers := rules.Local() != nil && len(rules.Local().Tiers) > 0
+	sb.WriteString("| | Path | Device | Country | Score |")
+	for _, metric := range metrics {
+		title := string(metric)
+		if info, ok := budget.Info(metric); ok {
+			title = info.Short
+		}
+		fmt.Fprintf(&sb, " %s |", title)
+	}
+	if hasTiers {
+		sb.WriteString(" Tiers |")
+	}
+	sb.WriteString("\n|---|---|---|---|---:|")
+	for range metrics {
+		sb.WriteString("---|")
+	}
+	if hasTiers {
+		sb.WriteString("---|")
+	}
+	sb.WriteString("\n")
+
+	var details strings.Builder
+	tested := make([]vfrogapi.PerformanceReport, 0)
+	for _, export := range exports {
+		for _, report := range export.Data {
+			tested = append(tested, report)
+			result := rules.Evaluate(report)
+			path := report.Path
+			if group := export.Groups[report.Id]; group != "" {
+				path = fmt.Sprintf("[%s] %s", group, path)
+			}
+			score, _ := rules.MetricValue(report, budget.MetricPerformanceScore)
+			scoreCell := score.String()
+			if verdict, ok := result.Verdict(budget.MetricPerformanceScore); ok {
+				scoreCell = fmt.Sprintf("%s %s", levelEmoji(verdict.Level), verdict)
+			}
+			fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |", levelEmoji(result.Level()), markdownEscape(path), report.Device.Name, report.Country.Code, scoreCell)
+			for _, metric := range metrics {
+				verdict, ok := result.Verdict(metric)
+				if !ok {
+					value, _ := rules.MetricValue(report, metric)
+					fmt.Fprintf(&sb, " %s |", value)
+					continue
+				}
+				fmt.Fprintf(&sb, " %s %s |", levelEmoji(verdict.Level), verdict)
+			}
+			if hasTiers {
+				tiers := make([]string, 0, len(result.TierVerdicts))
+				for _, v := range result.TierVerdicts {
+					tiers = append(tiers, fmt.Sprintf("%s %s", levelEmoji(v.Level), v))
+				}
+				fmt.Fprintf(&sb, " %s |", markdownEscape(strings.Join(tiers, "<br>")))
+			}
+			sb.WriteString("\n")
+
+			writeMarkdownDetails(&details, path, report, result)
+		}
+	}
+
+	scores := rules.Scoring().PathScores(tested)
+	if len(scores) > 0 {
+		paths := make([]string, 0, len(scores))
+		for path := range scores {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		entries := make([]string, 0, len(paths))
+		for _, path := range paths {
+			entries = append(entries, fmt.Sprintf("%s %.0f", markdownEscape(path), scores[path]))
+		}
+		fmt.Fprintf(&sb, "\nPerformance score per path (Lighthouse %s weighting): %s\n", rules.Scoring().Version, strings.Join(entries, ", "))
+	}
+
+	//
+	// Collapsible details and web report links
+	fmt.Fprintf(&sb, "\n<details>\n<summary>Selectors, big payloads and notes</summary>\n\n%s</details>\n\n", details.String())
+	for _, export := range exports {
+		if export.Metadata.Uuid != "" {
+			fmt.Fprintf(&sb, "[Web report %s](%s)\n", export.Metadata.Uuid, reportWebUrl(export.Metadata.Uuid))
+		}
+	}
+	return sb.String()
+}
+
+// writeMarkdownDetails writes the LCP/CLS selectors, big payloads and the budget notes of a performance report
+func writeMarkdownDetails(sb *strings.Builder, path string, report vfrogapi.PerformanceReport, result budget.Result) {
+	fmt.Fprintf(sb, "**%s %s %s**\n\n", markdownEscape(path), report.Device.Name, report.Country.Code)
+	if selector := report.LargestContentfulPaint.Element.Selector; selector != "" {
+		fmt.Fprintf(sb, "- LCP element: `%s`\n", selector)
+	}
+	if report.CumulativeLayoutShift.Elements != nil {
+		for _, el := range *report.CumulativeLayoutShift.Elements {
+			fmt.Fprintf(sb, "- CLS element: `%s`\n", el.Selector)
+		}
+	}
+	if len(report.BigPayloads.Payloads) > 0 {
+		total := budget.Value{Amount: float64(report.BigPayloads.TotalBytes), Unit: budget.UnitBytes}
+		fmt.Fprintf(sb, "- Big payloads (%s total):\n", total)
+		for _, p := range report.BigPayloads.Payloads {
+			size := budget.Value{Amount: float64(p.TotalBytes), Unit: budget.UnitBytes}
+			fmt.Fprintf(sb, "  - %s %s\n", size, p.Url)
+		}
+	}
+	for _, note := range localRuleNotes(result) {
+		fmt.Fprintf(sb, "- %s\n", markdownEscape(stripColor(note)))
+	}
+	sb.WriteString("\n")
+}
+
+// markdownEscape escapes the characters breaking a Markdown table cell
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// ansiEscape matches the color codes of the terminal output
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripColor removes the terminal colors from a note
+func stripColor(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"reflect"
+)
+
+// mergeCmd configures the merge command
+type mergeCmd struct {
+	Files  []string `kong:"arg,type='existingfile',help='Saved results (EXPORT_FILE) of the single shards'"`
+	Export string   `kong:"env='EXPORT_FILE',help='Save the merged result as json to this file'"`
+	JUnit  string   `kong:"name='junit',env='JUNIT_FILE',help='Write the budget verdicts of the merged result as JUnit XML to this file'"`
+	HTML   string   `kong:"name='html',env='HTML_FILE',help='Write a self-contained HTML report of the merged result to this file'"`
+
+	FailPolicy    failPolicy      `kong:"embed"`
+	Markdown      markdownSummary `kong:"embed"`
+	CWVAssessment bool            `kong:"default='true',negatable,env='CWV_ASSESSMENT',help='Print the Core Web Vitals assessment per path and device below the table'"`
+}
+
+func runMerge(cmd mergeCmd) {
+	if err := cmd.FailPolicy.check(); err != nil {
+		log.Fatalf("configCheck failed: %s", err)
+	}
+
+	exports := make([]resultExport, 0, len(cmd.Files))
+	for _, fileName := range cmd.Files {
+		export, err := readResultExport(fileName)
+		if err != nil {
+			log.Fatalf("could not readResultExport: %s", err)
+		}
+		exports = append(exports, *export)
+	}
+
+	merged, err := mergeResultExports(exports)
+	if err != nil {
+		log.Fatalf("could not mergeResultExports: %s", err)
+	}
+
+	//
+	// Print basic info
+	fmt.Print("\n----------\n")
+	fmt.Printf("\nMerged %d shards with %d performance reports\n", len(exports), len(merged.Data))
+	fmt.Printf("Costs %d tokens\n", merged.Metadata.Cost)
+	for _, export := range exports {
+		fmt.Printf("Report web url %s\n", reportWebUrl(export.Metadata.Uuid))
+	}
+	fmt.Print("\n----------\n")
+	rules := merged.rules()
+	printBudgetProblems(rules)
+
+	//
+	// Write merged performance report table
+	levels := writeReportTable(os.Stdout, rules, merged.Data, merged.Groups, cmd.CWVAssessment)
+	expired := printExpiredSuppressions(rules)
+	var written resultFiles
+	if cmd.Export != "" {
+		written.check(writeResultExport(cmd.Export, merged), "could not writeResultExport")
+	}
+	written.check(cmd.Markdown.write(rules, exports, levels, expired, cmd.FailPolicy, ""), "could not write Markdown summary")
+	if cmd.JUnit != "" {
+		// The shards keep the report url of every performance report
+		written.check(writeJUnit(cmd.JUnit, rules, exports), "could not writeJUnit")
+	}
+	if cmd.HTML != "" {
+		written.check(writeHTMLReport(cmd.HTML, rules, exports, levels, expired, cmd.FailPolicy, ""), "could not writeHTMLReport")
+	}
+	defer printBudgetVerdict(levels, expired, cmd.FailPolicy, written)
+
+	if merged.PerformanceBudgets != nil {
+		if jsonBudgets, err := json.Marshal(merged.PerformanceBudgets.Budgets); err == nil {
+			fmt.Printf("\n----------\n\nPerformance Budgets:\n%s\n\n----------\n", string(jsonBudgets))
+		}
+	}
+}
+
+// mergeResultExports combines the exports of all shards into one. The metadata of the first shard is used as base,
+// the costs are summed up and the tested paths are combined. All shards must be judged against the same budgets.
+func mergeResultExports(exports []resultExport) (resultExport, error) {
+	if len(exports) == 0 {
+		return resultExport{}, fmt.Errorf("need at least a single export to merge")
+	}
+
+	merged := resultExport{
+		Report: vfrogapi.Report{
+			Data:     make([]vfrogapi.PerformanceReport, 0),
+			Metadata: exports[0].Metadata,
+		},
+		PerformanceBudgets: exports[0].PerformanceBudgets,
+		BudgetsConfig:      exports[0].BudgetsConfig,
+		Baseline:           exports[0].Baseline,
+		Suppressions:       exports[0].Suppressions,
+		Scoring:            exports[0].Scoring,
+	}
+	merged.Metadata.Cost = 0
+
+	paths := make([]string, 0)
+	for k, export := range exports {
+		if !reflect.DeepEqual(export.PerformanceBudgets, merged.PerformanceBudgets) || !reflect.DeepEqual(export.BudgetsConfig, merged.BudgetsConfig) || !reflect.DeepEqual(export.Suppressions, merged.Suppressions) {
+			return resultExport{}, fmt.Errorf("export %d was judged against different performance budgets than export 0", k)
+		}
+		if export.Scoring != merged.Scoring {
+			return resultExport{}, fmt.Errorf("export %d was scored with weighting %q, export 0 with %q", k, export.Scoring, merged.Scoring)
+		}
+
+		merged.Data = append(merged.Data, export.Data...)
+		for id, group := range export.Groups {
+			if merged.Groups == nil {
+				merged.Groups = map[int32]string{}
+			}
+			merged.Groups[id] = group
+		}
+		merged.Metadata.Cost += export.Metadata.Cost
+		if export.Metadata.Created.Before(merged.Metadata.Created) {
+			merged.Metadata.Created = export.Metadata.Created
+		}
+		if export.Metadata.Finished != nil && (merged.Metadata.Finished == nil || export.Metadata.Finished.After(*merged.Metadata.Finished)) {
+			merged.Metadata.Finished = export.Metadata.Finished
+		}
+		paths = append(paths, targetPaths(export.Metadata.Config.Target)...)
+	}
+
+	merged.Metadata.Config.Target.Paths = vfrogapi.ManualPathSelection{
+		Mode:  "manual",
+		Paths: paths,
+	}
+	merged.setScores(merged.rules().Scoring())
+	return merged, nil
+}
+
+// targetPaths returns the manual configured paths of a target. Target.Paths is untyped, so after reading it from json
+// it is a map and not a vfrogapi.ManualPathSelection
+func targetPaths(target vfrogapi.Target) []string {
+	switch paths := target.Paths.(type) {
+	case vfrogapi.ManualPathSelection:
+		return paths.Paths
+	case map[string]interface{}:
+		raw, _ := paths["paths"].([]interface{})
+		result := make([]string, 0, len(raw))
+		for _, p := range raw {
+			if s, ok := p.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"github.com/vitalfrog/jsonl"
+	"os"
+)
+
+// valueOutput is a metric value or threshold in the unit of the metric
+type valueOutput struct {
+	Amount float64     `json:"amount"`
+	Unit   budget.Unit `json:"unit"`
+}
+
+func newValueOutput(v budget.Value) valueOutput {
+	return valueOutput{Amount: v.Amount, Unit: v.Unit}
+}
+
+type verdictOutput struct {
+	Metric     vfrogapi.PerformanceBudgetMetric `json:"metric"`
+	Level      budget.Level                     `json:"level"`
+	Value      valueOutput                      `json:"value"`
+	Warning    valueOutput                      `json:"warning"`
+	Error      valueOutput                      `json:"error"`
+	Mode       vfrogapi.PerformanceBudgetMode   `json:"mode"`
+	Rule       string                           `json:"rule"`
+	Suppressed string                           `json:"suppressed,omitempty"`
+}
+
+func newVerdictOutputs(verdicts []budget.Verdict) []verdictOutput {
+	outputs := make([]verdictOutput, 0, len(verdicts))
+	for _, v := range verdicts {
+		output := verdictOutput{
+			Metric:  v.Metric,
+			Level:   v.Level,
+			Value:   newValueOutput(v.Value),
+			Warning: newValueOutput(v.Warning),
+			Error:   newValueOutput(v.Error),
+			Mode:    v.Mode,
+			Rule:    v.Rule,
+		}
+		if v.Suppressed != nil {
+			output.Suppressed = v.Suppressed.String()
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+type ruleVerdictOutput struct {
+	Name   string            `json:"name"`
+	Level  budget.Level      `json:"level"`
+	Expr   string            `json:"expr"`
+	Values map[string]string `json:"values"`
+}
+
+type regressionVerdictOutput struct {
+	Metric       vfrogapi.PerformanceBudgetMetric `json:"metric"`
+	Level        budget.Level                     `json:"level"`
+	Baseline     valueOutput                      `json:"baseline"`
+	Current      valueOutput                      `json:"current"`
+	DeltaPercent float64                          `json:"delta_percent"`
+	Suppressed   string                           `json:"suppressed,omitempty"`
+}
+
+type tierVerdictOutput struct {
+	Tier           string            `json:"tier"`
+	Effect         budget.TierEffect `json:"effect"`
+	Level          budget.Level      `json:"level"`
+	EffectiveLevel budget.Level      `json:"effective_level"`
+	Verdicts       []verdictOutput   `json:"verdicts"`
+}
+
+// rowOutput is a single performance report judged against the budgets, like a row of the table
+type rowOutput struct {
+	Type              string                     `json:"type"`
+	ReportUuid        string                     `json:"report_uuid"`
+	Group             string                     `json:"group,omitempty"`
+	Level             budget.Level               `json:"level"`
+	PerformanceScore  float64                    `json:"performance_score"`
+	PerformanceReport vfrogapi.PerformanceReport `json:"performance_report"`
+	Verdicts          []verdictOutput            `json:"verdicts"`
+	RuleVerdicts      []ruleVerdictOutput        `json:"rule_verdicts,omitempty"`
+	Regressions       []regressionVerdictOutput  `json:"regressions,omitempty"`
+	Tiers             []tierVerdictOutput        `json:"tiers,omitempty"`
+}
+
+func newRowOutput(rules budget.Rules, uuid, group string, result budget.Result) rowOutput {
+	score, _ := rules.MetricValue(result.Report, budget.MetricPerformanceScore)
+	row := rowOutput{
+		Type:              "row",
+		ReportUuid:        uuid,
+		Group:             group,
+		Level:             result.Level(),
+		PerformanceScore:  score.Amount,
+		PerformanceReport: result.Report,
+		Verdicts:          newVerdictOutputs(result.Verdicts),
+	}
+	for _, v := range result.RuleVerdicts {
+		row.RuleVerdicts = append(row.RuleVerdicts, ruleVerdictOutput{Name: v.Name, Level: v.Level, Expr: v.Expr, Values: v.Values})
+	}
+	for _, v := range result.RegressionVerdicts {
+		regression := regressionVerdictOutput{
+			Metric:       v.Metric,
+			Level:        v.Level,
+			Baseline:     newValueOutput(v.Baseline),
+			Current:      newValueOutput(v.Current),
+			DeltaPercent: v.DeltaPercent,
+		}
+		if v.Suppressed != nil {
+			regression.Suppressed = v.Suppressed.String()
+		}
+		row.Regressions = append(row.Regressions, regression)
+	}
+	for _, v := range result.TierVerdicts {
+		row.Tiers = append(row.Tiers, tierVerdictOutput{
+			Tier:           v.Tier,
+			Effect:         v.Effect,
+			Level:          v.Level,
+			EffectiveLevel: v.EffectiveLevel(),
+			Verdicts:       newVerdictOutputs(v.Verdicts),
+		})
+	}
+	return row
+}
+
+// summaryOutput is the overall result of the run
+type summaryOutput struct {
+	Type string `json:"type"`
+	// Failed reports whether the run fails according to the fail policy
+	Failed              bool               `json:"failed"`
+	Rows                rowLevels          `json:"rows"`
+	ExpiredSuppressions int                `json:"expired_suppressions"`
+	AppliedBudgets      string             `json:"applied_budgets"`
+	ScoreWeighting      string             `json:"score_weighting"`
+	PathScores          map[string]float64 `json:"path_scores"`
+}
+
+// resultDocument is the single document written by --output json
+type resultDocument struct {
+	Metadata []vfrogapi.ReportMetadata `json:"metadata"`
+	Rows     []rowOutput               `json:"rows"`
+	Result   summaryOutput             `json:"result"`
+}
+
+// resultOutput writes the machine readable output next to the table. jsonl streams a line per metadata, row and the
+// summary as they arrive, json writes a single document at the end
+type resultOutput struct {
+	format string
+	file   *os.File
+	lines  jsonl.Writer
+	rules  budget.Rules
+	doc    resultDocument
+	err    error
+}
+
+// newResultOutput creates the output file. Format is json or jsonl
+func newResultOutput(format, fileName string, rules budget.Rules) (*resultOutput, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %q: %w", fileName, err)
+	}
+	return &resultOutput{
+		format: format,
+		file:   file,
+		lines:  jsonl.NewWriter(file),
+		rules:  rules,
+		doc:    resultDocument{Metadata: make([]vfrogapi.ReportMetadata, 0), Rows: make([]rowOutput, 0)},
+	}, nil
+}
+
+// write streams a jsonl line. The first error is kept and returned by close
+func (o *resultOutput) write(line interface{}) {
+	if o.err != nil {
+		return
+	}
+	if err := o.lines.Write(line); err != nil {
+		o.err = fmt.Errorf("could not write line: %w", err)
+	}
+}
+
+// writeMetadata adds the metadata of a created report
+func (o *resultOutput) writeMetadata(metadata vfrogapi.ReportMetadata) {
+	if o.format == "jsonl" {
+		o.write(struct {
+			Type string `json:"type"`
+			vfrogapi.ReportMetadata
+		}{Type: "metadata", ReportMetadata: metadata})
+		return
+	}
+	o.doc.Metadata = append(o.doc.Metadata, metadata)
+}
+
+// rowWriter returns the function adding the rows of the report with the uuid, see reportTable.onResult
+func (o *resultOutput) rowWriter(uuid string) func(group string, result budget.Result) {
+	return func(group string, result budget.Result) {
+		row := newRowOutput(o.rules, uuid, group, result)
+		if o.format == "jsonl" {
+			o.write(row)
+			return
+		}
+		o.doc.Rows = append(o.doc.Rows, row)
+	}
+}
+
+// close writes the summary and closes the file
+func (o *resultOutput) close(tested []vfrogapi.PerformanceReport, levels rowLevels, expired int, policy failPolicy, appliedBudgets string) error {
+	summary := summaryOutput{
+		Type:                "result",
+		Failed:              policy.fails(levels, expired),
+		Rows:                levels,
+		ExpiredSuppressions: expired,
+		AppliedBudgets:      appliedBudgets,
+		ScoreWeighting:      o.rules.Scoring().Version,
+		PathScores:          o.rules.Scoring().PathScores(tested),
+	}
+	if o.format == "jsonl" {
+		o.write(summary)
+	} else if o.err == nil {
+		o.doc.Result = summary
+		raw, err := json.MarshalIndent(o.doc, "", "  ")
+		if err != nil {
+			o.err = fmt.Errorf("could not json marshal result: %w", err)
+		} else if _, err := o.file.Write(raw); err != nil {
+			o.err = fmt.Errorf("could not write result: %w", err)
+		}
+	}
+
+	if err := o.file.Close(); err != nil && o.err == nil {
+		o.err = fmt.Errorf("could not close %q: %w", o.file.Name(), err)
+	}
+	return o.err
+}
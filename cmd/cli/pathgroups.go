@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/internal/pathglob"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"os"
+)
+
+// defaultPathGroupName is the group of all paths not matched by any configured path group
+const defaultPathGroupName = "default"
+
+// pathGroup holds http settings for a set of paths. They are layered over the global BASIC_AUTH_*/EXTRA_HEADERS settings:
+// basic auth replaces the global one and extra headers overwrite global headers of the same name. An empty header value
+// removes the global header. With "inherit": false the group starts without any global basic auth and headers, e.g. to
+// test pages logged out while the session cookie is set globally.
+// Values may reference environment variables (e.g. "session=${SESSION_COOKIE}") to keep secrets out of the file.
+type pathGroup struct {
+	Name string `json:"name"`
+	// Globs matching the paths of this group. A path is part of the first group it matches
+	Paths        []string            `json:"paths"`
+	BasicAuth    *vfrogapi.BasicAuth `json:"basic_auth,omitempty"`
+	ExtraHeaders map[string]string   `json:"extra_headers,omitempty"`
+	// Inherit the global basic auth and headers. Defaults to true
+	Inherit *bool `json:"inherit,omitempty"`
+}
+
+// inherits reports whether the group is layered over the global http settings
+func (g pathGroup) inherits() bool {
+	return g.Inherit == nil || *g.Inherit
+}
+
+// pathGroupsFile is the format of PATH_GROUPS_FILE
+type pathGroupsFile struct {
+	Groups []pathGroup `json:"groups"`
+}
+
+// groupedConfig is the config of a single report created for a path group
+type groupedConfig struct {
+	name string
+	cfg  config
+}
+
+func readPathGroups(fileName string) ([]pathGroup, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+	groupsFile := pathGroupsFile{}
+	err = json.Unmarshal(raw, &groupsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not json unmarshal %q: %w", fileName, err)
+	}
+
+	seen := map[string]struct{}{defaultPathGroupName: {}}
+	for k, g := range groupsFile.Groups {
+		if g.Name == "" {
+			return nil, fmt.Errorf("path group %d has no name", k)
+		}
+		if _, ok := seen[g.Name]; ok {
+			return nil, fmt.Errorf("path group name %q is used twice or reserved", g.Name)
+		}
+		seen[g.Name] = struct{}{}
+		if len(g.Paths) == 0 {
+			return nil, fmt.Errorf("path group %q has no paths", g.Name)
+		}
+		for _, pattern := range g.Paths {
+			if !pathglob.Valid(pattern) {
+				return nil, fmt.Errorf("path group %q has invalid path glob %q", g.Name, pattern)
+			}
+		}
+		if g.BasicAuth != nil && (g.BasicAuth.Username == "" || g.BasicAuth.Password == "") {
+			return nil, fmt.Errorf("path group %q needs both basic_auth username and password", g.Name)
+		}
+	}
+	return groupsFile.Groups, nil
+}
+
+// groupConfigs splits the config into one config per path group. Without PATH_GROUPS_FILE the config is returned as is.
+// Groups without any path are left out.
+func (c config) groupConfigs() ([]groupedConfig, error) {
+	if c.PathGroupsFile == "" {
+		return []groupedConfig{{name: "", cfg: c}}, nil
+	}
+
+	groups, err := readPathGroups(c.PathGroupsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not readPathGroups: %w", err)
+	}
+
+	groupPaths := make([][]string, len(groups)+1)
+	for _, p := range c.TargetPaths {
+		k := len(groups)
+		for i, g := range groups {
+			if pathglob.MatchAny(g.Paths, normalizePath(p)) {
+				k = i
+				break
+			}
+		}
+		groupPaths[k] = append(groupPaths[k], p)
+	}
+
+	result := make([]groupedConfig, 0, len(groups)+1)
+	for k, g := range append(groups, pathGroup{Name: defaultPathGroupName}) {
+		if len(groupPaths[k]) == 0 {
+			continue
+		}
+		groupCfg := c
+		groupCfg.TargetPaths = groupPaths[k]
+		if !g.inherits() {
+			groupCfg.BasicAuthUsername, groupCfg.BasicAuthPassword = "", ""
+			groupCfg.ExtraHeaders = nil
+		}
+		if g.BasicAuth != nil {
+			groupCfg.BasicAuthUsername = os.ExpandEnv(g.BasicAuth.Username)
+			groupCfg.BasicAuthPassword = os.ExpandEnv(g.BasicAuth.Password)
+		}
+		if len(g.ExtraHeaders) > 0 {
+			headers := map[string]string{}
+			for header, value := range groupCfg.ExtraHeaders {
+				headers[header] = value
+			}
+			for header, value := range g.ExtraHeaders {
+				if value == "" {
+					delete(headers, header)
+					continue
+				}
+				headers[header] = os.ExpandEnv(value)
+			}
+			groupCfg.ExtraHeaders = headers
+		}
+		result = append(result, groupedConfig{name: g.Name, cfg: groupCfg})
+	}
+	return result, nil
+}
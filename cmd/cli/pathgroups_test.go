@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGroupConfigsHttpSettings(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "groups.json")
+	groups := `{"groups": [
+		{"name": "public", "paths": ["/public/**"], "inherit": false},
+		{"name": "admin", "paths": ["/admin/**"], "basic_auth": {"username": "admin", "password": "secret"}},
+		{"name": "anonymous", "paths": ["/anonymous/**"], "extra_headers": {"Cookie": "", "X-Variant": "b"}}
+	]}`
+	if err := os.WriteFile(fileName, []byte(groups), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := config{
+		PathGroupsFile:    fileName,
+		TargetPaths:       []string{"/public/a", "/admin/b", "/anonymous/c", "/d"},
+		BasicAuthUsername: "user",
+		BasicAuthPassword: "password",
+		ExtraHeaders:      map[string]string{"Cookie": "session=1", "X-Team": "web"},
+	}
+	grouped, err := c.groupConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		group    string
+		username string
+		headers  map[string]string
+	}{
+		{"public", "", nil},
+		{"admin", "admin", map[string]string{"Cookie": "session=1", "X-Team": "web"}},
+		{"anonymous", "user", map[string]string{"X-Team": "web", "X-Variant": "b"}},
+		{defaultPathGroupName, "user", map[string]string{"Cookie": "session=1", "X-Team": "web"}},
+	}
+	if len(grouped) != len(tests) {
+		t.Fatalf("got %d groups, want %d", len(grouped), len(tests))
+	}
+	for k, tt := range tests {
+		g := grouped[k]
+		if g.name != tt.group {
+			t.Errorf("group %d is %q, want %q", k, g.name, tt.group)
+		}
+		if g.cfg.BasicAuthUsername != tt.username {
+			t.Errorf("group %q has basic auth user %q, want %q", g.name, g.cfg.BasicAuthUsername, tt.username)
+		}
+		if len(g.cfg.ExtraHeaders) != len(tt.headers) || (len(tt.headers) > 0 && !reflect.DeepEqual(g.cfg.ExtraHeaders, tt.headers)) {
+			t.Errorf("group %q has headers %v, want %v", g.name, g.cfg.ExtraHeaders, tt.headers)
+		}
+	}
+	if c.ExtraHeaders["Cookie"] != "session=1" {
+		t.Errorf("global headers were modified: %v", c.ExtraHeaders)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/internal/pathglob"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resolveTargetPaths collects the paths of all configured path sources (TARGET_PATHS, PATHS_FILE, PATHS_SITEMAP, PATHS_DIR),
+// filters them by PATHS_INCLUDE / PATHS_EXCLUDE and returns them normalized and deduplicated
+func (c config) resolveTargetPaths() ([]string, error) {
+	paths := make([]string, 0, len(c.TargetPaths))
+	paths = append(paths, c.TargetPaths...)
+
+	if c.PathsFile != "" {
+		filePaths, err := readPathsFile(c.PathsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not readPathsFile: %w", err)
+		}
+		log.Debugf("Loaded %d paths from PATHS_FILE %q", len(filePaths), c.PathsFile)
+		paths = append(paths, filePaths...)
+	}
+
+	if c.PathsSitemap != "" {
+		sitemapPaths, err := readSitemap(c.PathsSitemap, 0)
+		if err != nil {
+			return nil, fmt.Errorf("could not readSitemap: %w", err)
+		}
+		log.Debugf("Loaded %d paths from PATHS_SITEMAP %q", len(sitemapPaths), c.PathsSitemap)
+		paths = append(paths, sitemapPaths...)
+	}
+
+	if c.PathsDir != "" {
+		dirPaths, err := readStaticSiteDir(c.PathsDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not readStaticSiteDir: %w", err)
+		}
+		log.Debugf("Loaded %d paths from PATHS_DIR %q", len(dirPaths), c.PathsDir)
+		paths = append(paths, dirPaths...)
+	}
+
+	for _, pattern := range append(append([]string{}, c.PathsInclude...), c.PathsExclude...) {
+		if !pathglob.Valid(pattern) {
+			return nil, fmt.Errorf("invalid path glob %q", pattern)
+		}
+	}
+
+	seen := map[string]struct{}{}
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = normalizePath(p)
+		if p == "" {
+			continue
+		}
+		if len(c.PathsInclude) > 0 && !pathglob.MatchAny(c.PathsInclude, p) {
+			continue
+		}
+		if pathglob.MatchAny(c.PathsExclude, p) {
+			continue
+		}
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// normalizePath turns a path or absolute url into a clean path with a leading '/'.
+// Query strings are kept, fragments are dropped. A trailing '/' is kept as it can be a different route. The path stays
+// escaped, so an encoded '/' (%2F) is not turned into a path separator and non ASCII characters are percent encoded.
+func normalizePath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ""
+	}
+
+	u, err := url.Parse(p)
+	if err != nil {
+		log.Debugf("could not parse path %q, using it as is: %s", p, err)
+		return p
+	}
+
+	cleaned := u.EscapedPath()
+	if cleaned == "" || !strings.HasPrefix(cleaned, "/") {
+		cleaned = "/" + cleaned
+	}
+	trailingSlash := strings.HasSuffix(cleaned, "/")
+	cleaned = path.Clean(cleaned)
+	if trailingSlash && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	if u.RawQuery != "" {
+		cleaned += "?" + u.RawQuery
+	}
+	return cleaned
+}
+
+// readPathsFile reads a newline delimited file of paths. Empty lines and lines starting with '#' are ignored
+func readPathsFile(fileName string) ([]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %q: %w", fileName, err)
+	}
+	defer f.Close()
+
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not scan %q: %w", fileName, err)
+	}
+	return paths, nil
+}
+
+// maxSitemapDepth limits how deep sitemap indexes are followed, to not loop forever on self referencing indexes
+const maxSitemapDepth = 3
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// readSitemap reads a local sitemap.xml (optionally gzip compressed). If the file is a sitemap index, the referenced
+// sitemaps are looked up by their file name in the directory of the index file.
+func readSitemap(fileName string, depth int) ([]string, error) {
+	if depth > maxSitemapDepth {
+		return nil, fmt.Errorf("sitemap index nesting deeper than %d at %q", maxSitemapDepth, fileName)
+	}
+
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+
+	// gzip magic number
+	if bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("could not create gzip reader for %q: %w", fileName, err)
+		}
+		raw, err = io.ReadAll(gz)
+		if err != nil {
+			return nil, fmt.Errorf("could not gunzip %q: %w", fileName, err)
+		}
+	}
+
+	doc := sitemapDocument{}
+	err = xml.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not xml unmarshal %q: %w", fileName, err)
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset":
+		paths := make([]string, 0, len(doc.URLs))
+		for _, u := range doc.URLs {
+			paths = append(paths, strings.TrimSpace(u.Loc))
+		}
+		return paths, nil
+	case "sitemapindex":
+		paths := make([]string, 0)
+		for _, s := range doc.Sitemaps {
+			loc, err := url.Parse(strings.TrimSpace(s.Loc))
+			if err != nil {
+				return nil, fmt.Errorf("invalid sitemap loc %q in %q: %w", s.Loc, fileName, err)
+			}
+			child := filepath.Join(filepath.Dir(fileName), path.Base(loc.Path))
+			childPaths, err := readSitemap(child, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("could not readSitemap of index entry %q: %w", s.Loc, err)
+			}
+			paths = append(paths, childPaths...)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("unknown sitemap root element <%s> in %q", doc.XMLName.Local, fileName)
+	}
+}
+
+// staticSiteErrorPages are the error and fallback pages static hosts serve instead of a missing route. They are not
+// routes of their own, so readStaticSiteDir skips them
+var staticSiteErrorPages = map[string]bool{
+	"200.html": true,
+	"404.html": true,
+	"500.html": true,
+	"50x.html": true,
+}
+
+// readStaticSiteDir walks a static build output directory (e.g. dist/) and maps html files to routes.
+// 'index.html' files map to their directory ('/blog/index.html' -> '/blog/'), other html files are kept as they are.
+// The error and fallback pages of staticSiteErrorPages are skipped.
+func readStaticSiteDir(dir string) ([]string, error) {
+	paths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".html") || staticSiteErrorPages[d.Name()] {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return fmt.Errorf("could not get relative path of %q: %w", p, err)
+		}
+		route := "/" + filepath.ToSlash(rel)
+		if d.Name() == "index.html" {
+			route = strings.TrimSuffix(route, "index.html")
+		}
+		paths = append(paths, route)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk %q: %w", dir, err)
+	}
+	return paths, nil
+}
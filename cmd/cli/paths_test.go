@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"  /a  ", "/a"},
+		{"a/b", "/a/b"},
+		{"/a//b/../c", "/a/c"},
+		{"/a/", "/a/"},
+		{"/", "/"},
+		{"https://example.com/a/b?x=1#top", "/a/b?x=1"},
+		{"https://example.com", "/"},
+		{"/a%2Fb", "/a%2Fb"},
+		{"/caf%C3%A9", "/caf%C3%A9"},
+		{"/café", "/caf%C3%A9"},
+		{"/a b", "/a%20b"},
+		{"/a/./b/", "/a/b/"},
+	}
+	for _, tt := range tests {
+		if got := normalizePath(tt.in); got != tt.want {
+			t.Errorf("normalizePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadStaticSiteDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"index.html", "about.html", "blog/index.html", "blog/post.html", "style.css", "200.html", "404.html", "500.html", "50x.html", "blog/404.html"} {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := readStaticSiteDir(dir)
+	if err != nil {
+		t.Fatalf("readStaticSiteDir() error = %v", err)
+	}
+	sort.Strings(got)
+	want := []string{"/", "/about.html", "/blog/", "/blog/post.html"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readStaticSiteDir() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// samplingState is persisted in SAMPLE_STATE_FILE between runs. It holds which paths were already tested in the
+// current cycle, so successive runs rotate through all paths before any path is tested again.
+type samplingState struct {
+	Cycle   int       `json:"cycle"`
+	Tested  []string  `json:"tested"`
+	Updated time.Time `json:"updated"`
+}
+
+// samplePaths picks SAMPLE_SIZE paths out of the given ones. Pinned paths are always part of the sample, the rest is
+// distributed round-robin over the strata (url prefixes of SAMPLE_STRATIFY_DEPTH segments).
+// If a SAMPLE_STATE_FILE is configured, untested paths of the current cycle are preferred. The returned commit func
+// persists the new rotation state and should only be called once the report got created.
+func (c config) samplePaths(paths []string) ([]string, func() error, error) {
+	noop := func() error { return nil }
+	if c.SampleSize <= 0 || c.SampleSize >= len(paths) {
+		return paths, noop, nil
+	}
+
+	state := samplingState{}
+	if c.SampleStateFile != "" {
+		var err error
+		state, err = readSamplingState(c.SampleStateFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not readSamplingState: %w", err)
+		}
+	}
+
+	selected := make([]string, 0, c.SampleSize)
+	selectedSet := map[string]struct{}{}
+	add := func(p string) {
+		if _, ok := selectedSet[p]; ok || len(selected) >= c.SampleSize {
+			return
+		}
+		selectedSet[p] = struct{}{}
+		selected = append(selected, p)
+	}
+
+	for _, p := range c.SamplePinned {
+		add(normalizePath(p))
+	}
+
+	// Forget tested paths which are not part of the source anymore
+	known := map[string]struct{}{}
+	for _, p := range paths {
+		known[p] = struct{}{}
+	}
+	tested := map[string]struct{}{}
+	for _, p := range state.Tested {
+		if _, ok := known[p]; ok {
+			tested[p] = struct{}{}
+		}
+	}
+
+	untested := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if _, ok := tested[p]; !ok {
+			untested = append(untested, p)
+		}
+	}
+
+	for _, p := range c.stratifiedOrder(untested) {
+		add(p)
+	}
+
+	// All paths of this cycle are covered, start the next cycle with the remaining slots. Only the paths added for the
+	// new cycle and the pinned ones count as tested in it, the leftovers of the previous cycle are tested again
+	newCycle := selected
+	if len(selected) < c.SampleSize {
+		state.Cycle++
+		tested = map[string]struct{}{}
+		log.Infof("Sampling covered all %d paths, starting rotation cycle %d", len(paths), state.Cycle)
+		leftovers := len(selected)
+		for _, p := range c.stratifiedOrder(paths) {
+			add(p)
+		}
+		newCycle = append([]string{}, selected[leftovers:]...)
+		for _, p := range c.SamplePinned {
+			newCycle = append(newCycle, normalizePath(p))
+		}
+	}
+
+	for _, p := range newCycle {
+		tested[p] = struct{}{}
+	}
+	state.Tested = make([]string, 0, len(tested))
+	for p := range tested {
+		state.Tested = append(state.Tested, p)
+	}
+	sort.Strings(state.Tested)
+	state.Updated = time.Now()
+
+	log.Infof("Sampled %d of %d paths (cycle %d, %d/%d covered)", len(selected), len(paths), state.Cycle, len(state.Tested), len(paths))
+
+	commit := noop
+	if c.SampleStateFile != "" {
+		commit = func() error {
+			return writeSamplingState(c.SampleStateFile, state)
+		}
+	}
+	return selected, commit, nil
+}
+
+// stratifiedOrder orders the paths round-robin over their strata. Without a state file the order within a stratum is random.
+func (c config) stratifiedOrder(paths []string) []string {
+	strata := map[string][]string{}
+	keys := make([]string, 0)
+	for _, p := range paths {
+		key := stratumKey(p, c.SampleStratifyDepth)
+		if _, ok := strata[key]; !ok {
+			keys = append(keys, key)
+		}
+		strata[key] = append(strata[key], p)
+	}
+	sort.Strings(keys)
+
+	if c.SampleStateFile == "" {
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for _, key := range keys {
+			stratum := strata[key]
+			rnd.Shuffle(len(stratum), func(i, j int) { stratum[i], stratum[j] = stratum[j], stratum[i] })
+		}
+	}
+
+	ordered := make([]string, 0, len(paths))
+	for k := 0; len(ordered) < len(paths); k++ {
+		for _, key := range keys {
+			if k < len(strata[key]) {
+				ordered = append(ordered, strata[key][k])
+			}
+		}
+	}
+	return ordered
+}
+
+// stratumKey returns the first depth segments of the path. '/blog/2022/post?x=1' with depth 1 is '/blog'
+func stratumKey(p string, depth int) string {
+	if depth <= 0 {
+		return ""
+	}
+	p = strings.SplitN(p, "?", 2)[0]
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func readSamplingState(fileName string) (samplingState, error) {
+	state := samplingState{}
+	raw, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+	err = json.Unmarshal(raw, &state)
+	if err != nil {
+		return state, fmt.Errorf("could not json unmarshal %q: %w", fileName, err)
+	}
+	return state, nil
+}
+
+func writeSamplingState(fileName string, state samplingState) error {
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not json marshal sampling state: %w", err)
+	}
+	err = os.WriteFile(fileName, raw, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not write %q: %w", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSamplePathsRotation(t *testing.T) {
+	paths := []string{"/a", "/b", "/c", "/d", "/e"}
+	c := config{SampleSize: 2, SampleStateFile: filepath.Join(t.TempDir(), "state.json")}
+
+	tests := []struct {
+		selected []string
+		cycle    int
+		tested   []string
+	}{
+		{[]string{"/a", "/b"}, 0, []string{"/a", "/b"}},
+		{[]string{"/c", "/d"}, 0, []string{"/a", "/b", "/c", "/d"}},
+		// The leftover /e of cycle 0 does not count as tested in cycle 1
+		{[]string{"/e", "/a"}, 1, []string{"/a"}},
+		{[]string{"/b", "/c"}, 1, []string{"/a", "/b", "/c"}},
+		{[]string{"/d", "/e"}, 1, []string{"/a", "/b", "/c", "/d", "/e"}},
+		{[]string{"/a", "/b"}, 2, []string{"/a", "/b"}},
+	}
+	for run, tt := range tests {
+		selected, commit, err := c.samplePaths(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := commit(); err != nil {
+			t.Fatal(err)
+		}
+		state, err := readSamplingState(c.SampleStateFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(selected, tt.selected) {
+			t.Errorf("run %d selected %v, want %v", run+1, selected, tt.selected)
+		}
+		if state.Cycle != tt.cycle || !reflect.DeepEqual(state.Tested, tt.tested) {
+			t.Errorf("run %d state is cycle %d tested %v, want cycle %d tested %v", run+1, state.Cycle, state.Tested, tt.cycle, tt.tested)
+		}
+	}
+}
+
+func TestSamplePathsPinned(t *testing.T) {
+	paths := []string{"/a", "/b", "/c", "/d"}
+	c := config{SampleSize: 2, SamplePinned: []string{"/d"}, SampleStateFile: filepath.Join(t.TempDir(), "state.json")}
+
+	want := [][]string{{"/d", "/a"}, {"/d", "/b"}, {"/d", "/c"}, {"/d", "/a"}}
+	for run, w := range want {
+		selected, commit, err := c.samplePaths(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := commit(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(selected, w) {
+			t.Errorf("run %d selected %v, want %v", run+1, selected, w)
+		}
+	}
+}
+
+func TestStratumKey(t *testing.T) {
+	tests := []struct {
+		path  string
+		depth int
+		want  string
+	}{
+		{"/blog/2022/post?x=1", 1, "/blog"},
+		{"/blog/2022/post", 2, "/blog/2022"},
+		{"/blog", 3, "/blog"},
+		{"/", 1, "/"},
+		{"/blog/post", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := stratumKey(tt.path, tt.depth); got != tt.want {
+			t.Errorf("stratumKey(%q, %d) = %q, want %q", tt.path, tt.depth, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/score"
+	"github.com/fatih/color"
+	"io"
+	"sort"
+)
+
+// writePathScores writes the mean performance score of every path
+func writePathScores(w io.Writer, reports []vfrogapi.PerformanceReport, weighting score.Weighting) {
+	scores := weighting.PathScores(reports)
+	if len(scores) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(scores))
+	for p := range scores {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintf(w, "\nPerformance score per path (Lighthouse %s weighting):\n\n", weighting.Version)
+	for _, p := range paths {
+		fmt.Fprintf(w, "   %s %s\n", scoreColor(scores[p]).Sprintf("%3.0f", scores[p]), p)
+	}
+}
+
+// scoreColor colors a score like Lighthouse does: 90 and above is good, below 50 is poor
+func scoreColor(s float64) *color.Color {
+	switch {
+	case s >= 90:
+		return green
+	case s >= 50:
+		return yellow
+	}
+	return red
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// ciParallelism describes the env vars a CI system uses to expose the index of a parallel job
+type ciParallelism struct {
+	name      string
+	indexEnv  string
+	totalEnv  string
+	indexBase int
+}
+
+// knownCIParallelism are the CI systems we auto detect SHARD_INDEX/SHARD_TOTAL from
+var knownCIParallelism = []ciParallelism{
+	{name: "CircleCI", indexEnv: "CIRCLE_NODE_INDEX", totalEnv: "CIRCLE_NODE_TOTAL", indexBase: 0},
+	{name: "GitLab", indexEnv: "CI_NODE_INDEX", totalEnv: "CI_NODE_TOTAL", indexBase: 1},
+	{name: "Buildkite", indexEnv: "BUILDKITE_PARALLEL_JOB", totalEnv: "BUILDKITE_PARALLEL_JOB_COUNT", indexBase: 0},
+	{name: "Semaphore", indexEnv: "SEMAPHORE_JOB_INDEX", totalEnv: "SEMAPHORE_JOB_COUNT", indexBase: 1},
+	{name: "Azure Pipelines", indexEnv: "SYSTEM_JOBPOSITIONINPHASE", totalEnv: "SYSTEM_TOTALJOBSINPHASE", indexBase: 1},
+}
+
+// shardPaths returns the paths this job is responsible for. The paths are sorted and distributed round-robin, so every
+// job computes the same split independent of the order the paths were configured in.
+func (c config) shardPaths(paths []string) ([]string, error) {
+	index, total := c.ShardIndex, c.ShardTotal
+	if total == 0 && c.ShardAutoDetect {
+		var err error
+		index, total, err = detectCIShard()
+		if err != nil {
+			return nil, fmt.Errorf("could not detectCIShard: %w", err)
+		}
+	}
+	if total <= 1 {
+		return paths, nil
+	}
+
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+
+	sharded := make([]string, 0, len(sorted)/total+1)
+	for k, p := range sorted {
+		if k%total == index {
+			sharded = append(sharded, p)
+		}
+	}
+	log.Infof("Shard %d/%d tests %d of %d paths", index+1, total, len(sharded), len(paths))
+
+	if len(sharded) == 0 {
+		return nil, fmt.Errorf("shard %d/%d has no paths to test. Use less parallel jobs than paths", index+1, total)
+	}
+	return sharded, nil
+}
+
+// detectCIShard returns the zero based shard index and total of the first known CI system with parallelism env vars set.
+// Returns a total of 0 if none is found.
+func detectCIShard() (int, int, error) {
+	for _, ci := range knownCIParallelism {
+		rawIndex, rawTotal := os.Getenv(ci.indexEnv), os.Getenv(ci.totalEnv)
+		if rawIndex == "" || rawTotal == "" {
+			continue
+		}
+		index, err := strconv.Atoi(rawIndex)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s %q: %w", ci.indexEnv, rawIndex, err)
+		}
+		total, err := strconv.Atoi(rawTotal)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s %q: %w", ci.totalEnv, rawTotal, err)
+		}
+		index -= ci.indexBase
+		if total < 1 || index < 0 || index >= total {
+			return 0, 0, fmt.Errorf("%s parallelism out of range. %s=%q, %s=%q", ci.name, ci.indexEnv, rawIndex, ci.totalEnv, rawTotal)
+		}
+		log.Debugf("Detected %s parallelism: job %d of %d", ci.name, index+1, total)
+		return index, total, nil
+	}
+	return 0, 0, nil
+}
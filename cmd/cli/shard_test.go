@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShardPaths(t *testing.T) {
+	paths := []string{"/e", "/a", "/d", "/b", "/c"}
+	tests := []struct {
+		name         string
+		index, total int
+		want         []string
+	}{
+		{"not sharded", 0, 0, paths},
+		{"single shard", 0, 1, paths},
+		{"first of two", 0, 2, []string{"/a", "/c", "/e"}},
+		{"second of two", 1, 2, []string{"/b", "/d"}},
+		{"last of five", 4, 5, []string{"/e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config{ShardIndex: tt.index, ShardTotal: tt.total}.shardPaths(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shardPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (config{ShardIndex: 5, ShardTotal: 6}).shardPaths(paths); err == nil || !strings.Contains(err.Error(), "shard 6/6 has no paths") {
+		t.Errorf("shardPaths() error = %v, want an empty shard", err)
+	}
+}
+
+// TestShardPathsCoverAllPaths checks every path is tested by exactly one shard, regardless of the configured order
+func TestShardPathsCoverAllPaths(t *testing.T) {
+	paths := []string{"/x", "/a", "/m", "/b", "/z", "/c", "/y"}
+	reversed := make([]string, len(paths))
+	for k, p := range paths {
+		reversed[len(paths)-1-k] = p
+	}
+	seen := map[string]int{}
+	for index := 0; index < 3; index++ {
+		got, err := config{ShardIndex: index, ShardTotal: 3}.shardPaths(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, _ := config{ShardIndex: index, ShardTotal: 3}.shardPaths(reversed)
+		if !reflect.DeepEqual(got, other) {
+			t.Errorf("shard %d depends on the order of the paths: %v vs %v", index, got, other)
+		}
+		for _, p := range got {
+			seen[p]++
+		}
+	}
+	for _, p := range paths {
+		if seen[p] != 1 {
+			t.Errorf("%s is tested by %d shards, want 1", p, seen[p])
+		}
+	}
+}
+
+func TestDetectCIShard(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		index, total int
+		err          string
+	}{
+		{"none", nil, 0, 0, ""},
+		{"CircleCI is zero based", map[string]string{"CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4"}, 2, 4, ""},
+		{"GitLab is one based", map[string]string{"CI_NODE_INDEX": "2", "CI_NODE_TOTAL": "4"}, 1, 4, ""},
+		{"only the index", map[string]string{"CI_NODE_INDEX": "2"}, 0, 0, ""},
+		{"invalid index", map[string]string{"CI_NODE_INDEX": "x", "CI_NODE_TOTAL": "4"}, 0, 0, "invalid CI_NODE_INDEX"},
+		{"index out of range", map[string]string{"CI_NODE_INDEX": "5", "CI_NODE_TOTAL": "4"}, 0, 0, "GitLab parallelism out of range"},
+		{"one based index of 0", map[string]string{"SEMAPHORE_JOB_INDEX": "0", "SEMAPHORE_JOB_COUNT": "4"}, 0, 0, "Semaphore parallelism out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, ci := range knownCIParallelism {
+				t.Setenv(ci.indexEnv, "")
+				t.Setenv(ci.totalEnv, "")
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			index, total, err := detectCIShard()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("detectCIShard() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || index != tt.index || total != tt.total {
+				t.Errorf("detectCIShard() = %d, %d, %v, want %d, %d", index, total, err, tt.index, tt.total)
+			}
+		})
+	}
+}
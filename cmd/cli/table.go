@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/budget"
+	"github.com/fatih/color"
+	"github.com/vitalfrog/termtable"
+	"io"
+	"sort"
+	"strings"
+)
+
+// metricColumn is a table column showing a single metric
+type metricColumn struct {
+	metric vfrogapi.PerformanceBudgetMetric
+	title  string
+	width  int
+}
+
+var (
+	scoreColumn              = metricColumn{metric: budget.MetricPerformanceScore, title: "Score", width: 10}
+	fidColumn                = metricColumn{metric: vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, title: "Max First Input Delay", width: 20}
+	serverResponseTimeColumn = metricColumn{metric: vfrogapi.PerformanceBudgetMetricServerResponseTimeMs, title: "Server response time", width: 20}
+	interactiveColumn        = metricColumn{metric: vfrogapi.PerformanceBudgetMetricInteractiveMs, title: "Time to interactive", width: 20}
+	clsColumn                = metricColumn{metric: vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, title: "Cumulative Layout Shift", width: 40}
+	lcpColumn                = metricColumn{metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, title: "Largest Contentful Paint", width: 40}
+
+	// optionalColumns are only shown if a budget is defined for their metric
+	optionalColumns = []metricColumn{
+		{metric: vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs, title: "First Contentful Paint", width: 20},
+		{metric: vfrogapi.PerformanceBudgetMetricFirstMeaningfulPaintMs, title: "First Meaningful Paint", width: 20},
+		{metric: vfrogapi.PerformanceBudgetMetricSpeedIndexMs, title: "Speed Index", width: 20},
+		{metric: vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs, title: "Total Blocking Time", width: 20},
+		{metric: vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes, title: "Big payloads", width: 20},
+	}
+)
+
+// reportTable writes performance reports as rows to the cli. CLS and LCP are always the last two columns, as the
+// element selectors are written below them.
+type reportTable struct {
+	w       io.Writer
+	tt      *termtable.TermTable
+	columns []metricColumn
+	rules   budget.Rules
+	// levels counts the written rows per budget level
+	levels rowLevels
+	// tierLevels counts the written rows per tier and level of the tier
+	tierLevels map[string]rowLevels
+	// onResult is called with every written row, if set
+	onResult func(group string, result budget.Result)
+}
+
+// newReportTable creates the performance report table and writes its header.
+// Next to the default metrics, a column is added for every other metric the budget rules are defined for.
+func newReportTable(w io.Writer, rules budget.Rules) *reportTable {
+	columns := []metricColumn{scoreColumn, fidColumn, serverResponseTimeColumn, interactiveColumn}
+	budgetedMetrics := rules.Metrics()
+	for _, column := range optionalColumns {
+		for _, metric := range budgetedMetrics {
+			if metric == column.metric {
+				columns = append(columns, column)
+				break
+			}
+		}
+	}
+	columns = append(columns, clsColumn, lcpColumn)
+
+	header := []termtable.HeaderField{
+		{
+			Field: termtable.NewStringField("Path"),
+		},
+		{
+			Field: termtable.NewStringField("Country"),
+			Width: termtable.IntPointer(4),
+		},
+		{
+			Field: termtable.NewStringField("Device"),
+			Width: termtable.IntPointer(10),
+		},
+	}
+	for _, column := range columns {
+		header = append(header, termtable.HeaderField{
+			Field: termtable.NewStringField(column.title),
+			Width: termtable.IntPointer(column.width),
+		})
+	}
+
+	tt := termtable.New(w, " | ")
+	tt.WriteHeader(header)
+	tt.WriteRowDivider('=')
+	return &reportTable{w: w, tt: tt, columns: columns, rules: rules, levels: rowLevels{}, tierLevels: map[string]rowLevels{}}
+}
+
+// writeReportTable writes the finished performance reports as table, followed by the performance score per path and
+// optionally the Core Web Vitals assessment. Returns the number of rows per budget level
+func writeReportTable(w io.Writer, rules budget.Rules, reports []vfrogapi.PerformanceReport, groups map[int32]string, cwvAssessment bool) rowLevels {
+	tt := newReportTable(w, rules)
+	for _, performanceReport := range reports {
+		tt.writeRow(groups[performanceReport.Id], performanceReport)
+	}
+	tt.writeTierSummary()
+	writePathScores(w, reports, rules.Scoring())
+	if cwvAssessment {
+		writeCWVAssessment(w, reports)
+	}
+	return tt.levels
+}
+
+// writeRow evaluates the performance report against the budget rules and writes it as row, followed by the LCP/CLS
+// element selectors. Below, the local budget rules which produced verdicts are listed.
+// If the report belongs to a path group, the path is labeled with the group name.
+// Returns the highest budget level of the row.
+func (t *reportTable) writeRow(group string, report vfrogapi.PerformanceReport) budget.Level {
+	return t.writeReport(group, report, nil)
+}
+
+// writeAggregatedRow writes the aggregate of repeated runs as row. Below, the spread of the shown metrics is listed.
+// Returns the highest budget level of the row.
+func (t *reportTable) writeAggregatedRow(group string, aggregated budget.Aggregated) budget.Level {
+	spreads := make([]string, 0, len(t.columns))
+	for _, column := range t.columns {
+		for _, spread := range aggregated.Spreads {
+			if spread.Metric == column.metric && spread.Max.Amount > spread.Min.Amount {
+				spreads = append(spreads, spread.String())
+			}
+		}
+	}
+	runs := fmt.Sprintf("%d runs", aggregated.Runs)
+	if aggregated.Runs == 1 {
+		runs = "1 run"
+	}
+	note := fmt.Sprintf("~ %s, no spread", runs)
+	if len(spreads) > 0 {
+		note = fmt.Sprintf("~ %s: %s", runs, strings.Join(spreads, ", "))
+	}
+	return t.writeReport(group, aggregated.Report, []string{note})
+}
+
+// writeReport writes the row, selectors and notes of a performance report. Notes are written after the rule notes
+func (t *reportTable) writeReport(group string, report vfrogapi.PerformanceReport, notes []string) budget.Level {
+	result := t.rules.Evaluate(report)
+
+	path := report.Path
+	if group != "" {
+		path = fmt.Sprintf("[%s] %s", group, path)
+	}
+
+	row := []termtable.Field{
+		termtable.NewStringField(path),
+		termtable.NewStringField(report.Country.Code),
+		termtable.NewStringField(string(report.Device.Name)),
+	}
+	for _, column := range t.columns {
+		verdict, ok := result.Verdict(column.metric)
+		if !ok {
+			value, _ := t.rules.MetricValue(report, column.metric)
+			row = append(row, termtable.NewColorField(value.String(), white))
+			continue
+		}
+		// Show the relevant threshold next to the value
+		value := verdict.String()
+		switch {
+		case verdict.Level == budget.LevelFail:
+			value = fmt.Sprintf("✖ %s", value)
+		case verdict.Suppressed != nil:
+			value = fmt.Sprintf("⚑ %s", value)
+		}
+		row = append(row, termtable.NewColorField(value, levelColor(verdict.Level)))
+	}
+	t.tt.WriteRow(row)
+
+	lcpSelectorElements := strings.Split(report.LargestContentfulPaint.Element.Selector, ">")
+	for k, v := range lcpSelectorElements {
+		arrow := ">"
+		if k == 0 {
+			arrow = ""
+		}
+		lcpSelectorElements[k] = fmt.Sprintf("%s%s%s", termtable.WhiteSpace(k), arrow, strings.TrimSpace(v))
+	}
+	var clsSelectorElements []string
+
+	if report.CumulativeLayoutShift.Elements != nil {
+		for _, el := range *report.CumulativeLayoutShift.Elements {
+			elements := strings.Split(el.Selector, ">")
+			for k, v := range elements {
+				arrow := ">"
+				if k == 0 {
+					arrow = ""
+				}
+				clsSelectorElements = append(clsSelectorElements, fmt.Sprintf("%s%s%s", termtable.WhiteSpace(k), arrow, strings.TrimSpace(v)))
+			}
+		}
+	}
+
+	for k := 0; k < maxInt(len(lcpSelectorElements), len(clsSelectorElements)); k++ {
+		selectorRow := make([]termtable.Field, 0, len(row))
+		for len(selectorRow) < len(row)-2 {
+			selectorRow = append(selectorRow, termtable.NewEmptyField())
+		}
+		if k < len(clsSelectorElements) {
+			selectorRow = append(selectorRow, termtable.NewStringField(clsSelectorElements[k]))
+		} else {
+			selectorRow = append(selectorRow, termtable.NewEmptyField())
+		}
+		if k < len(lcpSelectorElements) {
+			selectorRow = append(selectorRow, termtable.NewStringField(lcpSelectorElements[k]))
+		} else {
+			selectorRow = append(selectorRow, termtable.NewEmptyField())
+		}
+		t.tt.WriteRow(selectorRow)
+	}
+
+	// Rule notes are written across the whole table width, to not be cut by the column widths
+	if len(result.TierVerdicts) > 0 {
+		notes = append([]string{tierNote(result.TierVerdicts)}, notes...)
+	}
+	for _, note := range append(localRuleNotes(result), notes...) {
+		fmt.Fprintf(t.w, "   %s\n", note)
+	}
+	if t.rules.Baseline() != nil && t.rules.Local() != nil && len(t.rules.Local().Regressions) > 0 && len(result.RegressionVerdicts) == 0 {
+		fmt.Fprintf(t.w, "   %s\n", "No baseline to compare regressions against")
+	}
+
+	t.tt.WriteRowDivider('-')
+
+	t.levels[result.Level()]++
+	if t.onResult != nil {
+		t.onResult(group, result)
+	}
+	for _, v := range result.TierVerdicts {
+		if t.tierLevels[v.Tier] == nil {
+			t.tierLevels[v.Tier] = rowLevels{}
+		}
+		t.tierLevels[v.Tier][v.Level]++
+	}
+	return result.Level()
+}
+
+// tierNote is the compact verdict of every tier, e.g. "◇ tiers: SLA ✔ | team target ✖ LCP | aspirational ✖ LCP, CLS"
+func tierNote(verdicts []budget.TierVerdict) string {
+	tiers := make([]string, 0, len(verdicts))
+	for _, v := range verdicts {
+		tiers = append(tiers, levelColor(v.Level).Sprint(v.String()))
+	}
+	return fmt.Sprintf("◇ tiers: %s", strings.Join(tiers, " | "))
+}
+
+// writeTierSummary writes how many rows pass each tier. Rows with warnings pass
+func (t *reportTable) writeTierSummary() {
+	if t.rules.Local() == nil || len(t.rules.Local().Tiers) == 0 {
+		return
+	}
+	fmt.Fprint(t.w, "\nTiers:\n")
+	for _, tier := range t.rules.Local().Tiers {
+		if !tier.Resolved() {
+			yellow.Fprintf(t.w, "   %s (%s): no budgets, performance budgets %d are not fetched\n", tier.Name, tier.Effect, *tier.PerformanceBudgetsId)
+			continue
+		}
+		levels := t.tierLevels[tier.Name]
+		summary := fmt.Sprintf("   %s (%s): %d of %d rows pass", tier.Name, tier.Effect, levels.total()-levels[budget.LevelFail], levels.total())
+		if levels[budget.LevelWarn] > 0 {
+			summary += fmt.Sprintf(", %d of them with warnings", levels[budget.LevelWarn])
+		}
+		levelColor(levels.highest()).Fprintln(t.w, summary)
+	}
+}
+
+// localRuleNotes lists which metrics were judged by local budgets or overrides (e.g. "↳ override checkout: LCP, CLS")
+// and which expression rules are violated, with the values involved (e.g. "✖ rule lazy-lcp (lcp=4100, ...)").
+// Regressions are listed with baseline, current value and delta, colored by their verdict. Suppressed failures are
+// listed with the reason, owner and expiry of their suppression (e.g. "⚑ suppressed LCP until 2026-12-31 by jane: video").
+func localRuleNotes(result budget.Result) []string {
+	sources := make([]string, 0)
+	metrics := map[string][]string{}
+	for _, v := range result.Verdicts {
+		if budget.FromServer(v.Rule) {
+			continue
+		}
+		if _, ok := metrics[v.Rule]; !ok {
+			sources = append(sources, v.Rule)
+		}
+		short := string(v.Metric)
+		if info, ok := budget.Info(v.Metric); ok {
+			short = info.Short
+		}
+		metrics[v.Rule] = append(metrics[v.Rule], short)
+	}
+
+	notes := make([]string, 0, len(sources))
+	for _, source := range sources {
+		notes = append(notes, fmt.Sprintf("↳ %s: %s", source, strings.Join(metrics[source], ", ")))
+	}
+	for _, v := range result.RuleVerdicts {
+		switch v.Level {
+		case budget.LevelWarn:
+			notes = append(notes, yellow.Sprintf("! rule %s", v))
+		case budget.LevelFail:
+			notes = append(notes, red.Sprintf("✖ rule %s", v))
+		}
+	}
+	suppressed := map[*budget.Suppression]struct{}{}
+	for _, v := range result.RegressionVerdicts {
+		prefix := "↳"
+		switch {
+		case v.Level == budget.LevelFail:
+			prefix = "✖"
+		case v.Suppressed != nil:
+			prefix = "⚑"
+			suppressed[v.Suppressed] = struct{}{}
+		}
+		notes = append(notes, levelColor(v.Level).Sprintf("%s regression %s", prefix, v))
+	}
+	for _, v := range result.Verdicts {
+		if v.Suppressed != nil {
+			suppressed[v.Suppressed] = struct{}{}
+		}
+	}
+	for _, s := range sortedSuppressions(suppressed) {
+		notes = append(notes, yellow.Sprintf("⚑ suppressed %s", s))
+	}
+	return notes
+}
+
+// sortedSuppressions returns the suppressions ordered by their formatting, to get stable notes
+func sortedSuppressions(set map[*budget.Suppression]struct{}) []string {
+	formatted := make([]string, 0, len(set))
+	for s := range set {
+		formatted = append(formatted, s.String())
+	}
+	sort.Strings(formatted)
+	return formatted
+}
+
+func maxInt(a, v int) int {
+	if a > v {
+		return a
+	}
+	return v
+}
+
+var (
+	green  = color.New(color.FgGreen)
+	yellow = color.New(color.FgYellow)
+	red    = color.New(color.FgRed)
+	white  = color.New(color.FgWhite)
+)
+
+func levelColor(level budget.Level) *color.Color {
+	switch level {
+	case budget.LevelOk:
+		return green
+	case budget.LevelWarn:
+		return yellow
+	}
+	return red
+}
@@ -0,0 +1,57 @@
+// Package pathglob matches url paths against shell like glob patterns.
+//
+// Supported are '*' (any characters but '/'), '**' (any characters including '/') and '?' (a single character but '/').
+package pathglob
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Match reports whether the url path matches the glob pattern
+func Match(pattern, p string) bool {
+	re, err := compile(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(p)
+}
+
+// MatchAny reports whether the url path matches at least one of the glob patterns
+func MatchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether the given pattern can be used for matching
+func Valid(pattern string) bool {
+	_, err := compile(pattern)
+	return err == nil
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	runes := []rune(pattern)
+	for k := 0; k < len(runes); k++ {
+		switch r := runes[k]; r {
+		case '*':
+			if k+1 < len(runes) && runes[k+1] == '*' {
+				sb.WriteString(".*")
+				k++
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,178 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"math"
+	"sort"
+)
+
+// Aggregation is how repeated runs of the same path, device and country are combined into a single value per metric
+type Aggregation string
+
+const (
+	// AggregationMedian takes the median of the runs
+	AggregationMedian Aggregation = "median"
+	// AggregationP75 takes the 75th percentile of the runs
+	AggregationP75 Aggregation = "p75"
+	// AggregationMin takes the best run
+	AggregationMin Aggregation = "min"
+)
+
+// Spread describes how much a metric varied across repeated runs
+type Spread struct {
+	Metric vfrogapi.PerformanceBudgetMetric
+	Min    Value
+	Max    Value
+	StdDev Value
+	// Variation is the standard deviation relative to the mean in percent (coefficient of variation). 0 if the mean is 0
+	Variation float64
+}
+
+// String formats range and variation. E.g. "LCP 2500ms–2900ms ±6.1%"
+func (s Spread) String() string {
+	name := string(s.Metric)
+	if info, ok := Info(s.Metric); ok {
+		name = info.Short
+	}
+	return fmt.Sprintf("%s %s–%s ±%.1f%%", name, s.Min, s.Max, s.Variation)
+}
+
+// Aggregated is a performance report combined from repeated runs of the same path, device and country
+type Aggregated struct {
+	// Report holds the aggregated metric values. Everything else (selectors, network requests, ...) is taken from the
+	// run with the largest contentful paint closest to the aggregated one
+	Report vfrogapi.PerformanceReport
+	Runs   int
+	// Spreads of all metrics apart from computed ones, ordered like Metrics. Computed metrics are derived from the
+	// aggregated ones
+	Spreads []Spread
+}
+
+// Aggregate groups the performance reports by ReportKey and aggregates every group. Groups are returned in order of their
+// first report.
+func Aggregate(reports []vfrogapi.PerformanceReport, aggregation Aggregation) ([]Aggregated, error) {
+	keys := make([]string, 0)
+	runs := map[string][]vfrogapi.PerformanceReport{}
+	for _, report := range reports {
+		key := ReportKey(report)
+		if _, ok := runs[key]; !ok {
+			keys = append(keys, key)
+		}
+		runs[key] = append(runs[key], report)
+	}
+
+	result := make([]Aggregated, 0, len(keys))
+	for _, key := range keys {
+		aggregated, err := aggregateRuns(runs[key], aggregation)
+		if err != nil {
+			return nil, fmt.Errorf("could not aggregate %q: %w", key, err)
+		}
+		result = append(result, aggregated)
+	}
+	return result, nil
+}
+
+func aggregateRuns(runs []vfrogapi.PerformanceReport, aggregation Aggregation) (Aggregated, error) {
+	aggregated := Aggregated{Runs: len(runs), Spreads: make([]Spread, 0, len(metricInfos))}
+	values := map[vfrogapi.PerformanceBudgetMetric]Value{}
+	for _, info := range metricInfos {
+		if info.Computed {
+			continue
+		}
+		amounts := make([]float64, 0, len(runs))
+		for _, run := range runs {
+			v, _ := MetricValue(run, info.Metric)
+			amounts = append(amounts, v.Amount)
+		}
+		sort.Float64s(amounts)
+
+		var amount float64
+		switch aggregation {
+		case AggregationMedian:
+			amount = percentile(amounts, 0.5)
+		case AggregationP75:
+			amount = percentile(amounts, 0.75)
+		case AggregationMin:
+			amount = amounts[0]
+		default:
+			return Aggregated{}, fmt.Errorf("unknown aggregation %q", aggregation)
+		}
+		values[info.Metric] = Value{Amount: amount, Unit: info.Unit}
+
+		mean, stdDev := meanStdDev(amounts)
+		spread := Spread{
+			Metric: info.Metric,
+			Min:    Value{Amount: amounts[0], Unit: info.Unit},
+			Max:    Value{Amount: amounts[len(amounts)-1], Unit: info.Unit},
+			StdDev: Value{Amount: stdDev, Unit: info.Unit},
+		}
+		if mean != 0 {
+			spread.Variation = stdDev / mean * 100
+		}
+		aggregated.Spreads = append(aggregated.Spreads, spread)
+	}
+
+	//
+	// Take selectors and requests from the run closest to the aggregated LCP, so they match the shown values best
+	lcp := values[vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs].Amount
+	representative := runs[0]
+	for _, run := range runs[1:] {
+		if math.Abs(float64(run.LargestContentfulPaint.ValueMs)-lcp) < math.Abs(float64(representative.LargestContentfulPaint.ValueMs)-lcp) {
+			representative = run
+		}
+	}
+	for metric, value := range values {
+		setMetricValue(&representative, metric, value)
+	}
+	aggregated.Report = representative
+	return aggregated, nil
+}
+
+// percentile interpolates linearly between the closest ranks of the sorted amounts
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func meanStdDev(amounts []float64) (float64, float64) {
+	var sum float64
+	for _, a := range amounts {
+		sum += a
+	}
+	mean := sum / float64(len(amounts))
+	var squares float64
+	for _, a := range amounts {
+		squares += (a - mean) * (a - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(amounts)))
+}
+
+// setMetricValue is the counterpart of MetricValue. Integer metrics are rounded
+func setMetricValue(report *vfrogapi.PerformanceReport, metric vfrogapi.PerformanceBudgetMetric, value Value) {
+	ms := int32(math.Round(value.Amount))
+	switch metric {
+	case vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:
+		report.FirstContentfulPaint.ValueMs = ms
+	case vfrogapi.PerformanceBudgetMetricFirstMeaningfulPaintMs:
+		report.FirstMeaningfulPaintMs = ms
+	case vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs:
+		report.LargestContentfulPaint.ValueMs = ms
+	case vfrogapi.PerformanceBudgetMetricSpeedIndexMs:
+		report.SpeedIndexMs = ms
+	case vfrogapi.PerformanceBudgetMetricInteractiveMs:
+		report.InteractiveMs = ms
+	case vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:
+		report.TotalBlockingTimeMs = ms
+	case vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs:
+		report.MaxPotentialFidMs = ms
+	case vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:
+		report.CumulativeLayoutShift.Value = float32(value.Amount)
+	case vfrogapi.PerformanceBudgetMetricServerResponseTimeMs:
+		report.ServerResponseTimeMs = ms
+	case vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes:
+		report.BigPayloads.TotalBytes = ms
+	}
+}
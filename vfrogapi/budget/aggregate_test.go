@@ -0,0 +1,90 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"math"
+	"strings"
+	"testing"
+)
+
+func runReport(id int32, path string, lcp int32, cls float32) vfrogapi.PerformanceReport {
+	return vfrogapi.PerformanceReport{
+		Id:                     id,
+		Path:                   path,
+		Device:                 vfrogapi.Device{Name: vfrogapi.Mobile},
+		LargestContentfulPaint: vfrogapi.LargestContentfulPaint{ValueMs: lcp},
+		CumulativeLayoutShift:  vfrogapi.CumulativeLayoutShift{Value: cls},
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	even := []vfrogapi.PerformanceReport{
+		runReport(1, "/", 3000, 0.1), runReport(2, "/", 2000, 0.3), runReport(3, "/", 4000, 0.2), runReport(4, "/", 2500, 0.05),
+	}
+	odd := []vfrogapi.PerformanceReport{runReport(1, "/", 3000, 0.1), runReport(2, "/", 2000, 0.3), runReport(3, "/", 4000, 0.2)}
+	tests := []struct {
+		name        string
+		runs        []vfrogapi.PerformanceReport
+		aggregation Aggregation
+		lcp         float64
+		cls         float64
+		// representative is the run the other fields are taken from
+		representative int32
+	}{
+		{"median of an even count, ties keep the first run", even, AggregationMedian, 2750, 0.15, 1},
+		{"median of an odd count", odd, AggregationMedian, 3000, 0.2, 1},
+		{"p75 of an even count", even, AggregationP75, 3250, 0.225, 1},
+		{"p75 of an odd count", odd, AggregationP75, 3500, 0.25, 1},
+		{"min", even, AggregationMin, 2000, 0.05, 2},
+		{"single run", even[:1], AggregationP75, 3000, 0.1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregated, err := Aggregate(tt.runs, tt.aggregation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(aggregated) != 1 || aggregated[0].Runs != len(tt.runs) {
+				t.Fatalf("Aggregate() = %d groups, want 1 of %d runs", len(aggregated), len(tt.runs))
+			}
+			report := aggregated[0].Report
+			if lcp, _ := MetricValue(report, vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs); lcp.Amount != tt.lcp {
+				t.Errorf("LCP = %v, want %v", lcp.Amount, tt.lcp)
+			}
+			if cls, _ := MetricValue(report, vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift); math.Abs(cls.Amount-tt.cls) > 1e-9 {
+				t.Errorf("CLS = %v, want %v", cls.Amount, tt.cls)
+			}
+			if report.Id != tt.representative {
+				t.Errorf("representative run = %d, want %d", report.Id, tt.representative)
+			}
+		})
+	}
+}
+
+func TestAggregateGroupsAndSpreads(t *testing.T) {
+	reports := []vfrogapi.PerformanceReport{
+		runReport(1, "/b", 2000, 0), runReport(2, "/a", 1000, 0), runReport(3, "/b", 4000, 0),
+	}
+	aggregated, err := Aggregate(reports, AggregationMedian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggregated) != 2 || aggregated[0].Report.Path != "/b" || aggregated[1].Report.Path != "/a" {
+		t.Fatalf("groups are not in order of their first report: %+v", aggregated)
+	}
+	for _, spread := range aggregated[0].Spreads {
+		if spread.Metric != vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs {
+			continue
+		}
+		if spread.Min.Amount != 2000 || spread.Max.Amount != 4000 || spread.StdDev.Amount != 1000 {
+			t.Errorf("spread = %+v, want 2000–4000 with a standard deviation of 1000", spread)
+		}
+		if math.Abs(spread.Variation-100.0/3) > 1e-9 {
+			t.Errorf("variation = %v, want 33.3", spread.Variation)
+		}
+	}
+
+	if _, err := Aggregate(reports, "mean"); err == nil || !strings.Contains(err.Error(), `unknown aggregation "mean"`) {
+		t.Errorf("Aggregate() error = %v, want an unknown aggregation", err)
+	}
+}
@@ -0,0 +1,240 @@
+// Package budget evaluates VitalFrog performance reports against performance budgets.
+//
+// Units of the budget metrics:
+//   - all '*_ms' metrics are milliseconds
+//   - 'big_payloads.total_bytes' is bytes
+//   - 'cumulative_layout_shift' is a unitless score. Budget thresholds are integers, so it is budgeted in hundredths:
+//     a warning of 10 means a CLS of 0.10. Values are compared exactly, a CLS of 0.109 crosses a threshold of 10.
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/score"
+)
+
+// Level is the severity of a verdict
+type Level int
+
+const (
+	// LevelOk means the metric is within the budget
+	LevelOk Level = iota
+	// LevelWarn means the metric crossed the warning threshold
+	LevelWarn
+	// LevelFail means the metric crossed the error threshold
+	LevelFail
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelOk:
+		return "ok"
+	case LevelWarn:
+		return "warn"
+	case LevelFail:
+		return "fail"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// MarshalText marshals the level as its name, e.g. "warn"
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Metrics are all metrics a budget can be defined for
+var Metrics = func() []vfrogapi.PerformanceBudgetMetric {
+	metrics := make([]vfrogapi.PerformanceBudgetMetric, 0, len(metricInfos))
+	for _, info := range metricInfos {
+		metrics = append(metrics, info.Metric)
+	}
+	return metrics
+}()
+
+// KnownMetric reports whether the metric is one of Metrics
+func KnownMetric(metric vfrogapi.PerformanceBudgetMetric) bool {
+	_, ok := Info(metric)
+	return ok
+}
+
+// MetricValue returns the value of the metric in the unit of the metric (see Info). Returns false for unknown metrics.
+// The performance score is computed with the default weighting, see Rules.MetricValue for the selected one.
+func MetricValue(report vfrogapi.PerformanceReport, metric vfrogapi.PerformanceBudgetMetric) (Value, bool) {
+	ms := func(v int32) (Value, bool) {
+		return Value{Amount: float64(v), Unit: UnitMilliseconds}, true
+	}
+	switch metric {
+	case vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:
+		return ms(report.FirstContentfulPaint.ValueMs)
+	case vfrogapi.PerformanceBudgetMetricFirstMeaningfulPaintMs:
+		return ms(report.FirstMeaningfulPaintMs)
+	case vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs:
+		return ms(report.LargestContentfulPaint.ValueMs)
+	case vfrogapi.PerformanceBudgetMetricSpeedIndexMs:
+		return ms(report.SpeedIndexMs)
+	case vfrogapi.PerformanceBudgetMetricInteractiveMs:
+		return ms(report.InteractiveMs)
+	case vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:
+		return ms(report.TotalBlockingTimeMs)
+	case vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs:
+		return ms(report.MaxPotentialFidMs)
+	case vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:
+		return Value{Amount: report.CumulativeLayoutShift.Exact(), Unit: UnitScore}, true
+	case vfrogapi.PerformanceBudgetMetricServerResponseTimeMs:
+		return ms(report.ServerResponseTimeMs)
+	case vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes:
+		return Value{Amount: float64(report.BigPayloads.TotalBytes), Unit: UnitBytes}, true
+	case MetricPerformanceScore:
+		return Value{Amount: score.Default().Score(report), Unit: UnitScore}, true
+	}
+	return Value{}, false
+}
+
+// Verdict is the result of a single budget applied to a single performance report.
+// Value and thresholds are all in the unit of the metric.
+type Verdict struct {
+	Metric  vfrogapi.PerformanceBudgetMetric
+	Level   Level
+	Value   Value
+	Warning Value
+	Error   Value
+	Mode    vfrogapi.PerformanceBudgetMode
+	// Rule names the budget the verdict was produced by. See Rule.Source
+	Rule string
+	// Suppressed is the suppression which downgraded the verdict from fail to warn. Nil if not suppressed
+	Suppressed *Suppression
+	// Exclusive verdicts pass values at the thresholds, like the presets do
+	Exclusive bool
+}
+
+// Threshold returns the threshold relevant for the verdict. The error threshold if failed or suppressed, otherwise the
+// warning threshold
+func (v Verdict) Threshold() Value {
+	if v.Level == LevelFail || v.Suppressed != nil {
+		return v.Error
+	}
+	return v.Warning
+}
+
+// String formats the value next to the threshold relevant for the verdict. E.g. "2700ms ≥ 2500ms"
+func (v Verdict) String() string {
+	op := "<"
+	switch {
+	case v.Mode == vfrogapi.Below && v.Level == LevelOk:
+		op = ">"
+	case v.Mode == vfrogapi.Below:
+		op = "≤"
+	case v.Level != LevelOk:
+		op = "≥"
+	}
+	if v.Exclusive {
+		op = map[string]string{"<": "≤", "≥": ">", ">": "≥", "≤": "<"}[op]
+	}
+	return fmt.Sprintf("%s %s %s", v.Value, op, v.Threshold())
+}
+
+// Result holds the verdicts of all budgets and rules applied to a single performance report
+type Result struct {
+	Report             vfrogapi.PerformanceReport
+	Verdicts           []Verdict
+	RuleVerdicts       []RuleVerdict
+	RegressionVerdicts []RegressionVerdict
+	TierVerdicts       []TierVerdict
+}
+
+// Level returns the highest level of all verdicts. Tiers contribute their level depending on their effect
+func (r Result) Level() Level {
+	level := LevelOk
+	for _, v := range r.Verdicts {
+		if v.Level > level {
+			level = v.Level
+		}
+	}
+	for _, v := range r.RuleVerdicts {
+		if v.Level > level {
+			level = v.Level
+		}
+	}
+	for _, v := range r.RegressionVerdicts {
+		if v.Level > level {
+			level = v.Level
+		}
+	}
+	for _, v := range r.TierVerdicts {
+		if v.EffectiveLevel() > level {
+			level = v.EffectiveLevel()
+		}
+	}
+	return level
+}
+
+// Verdict returns the verdict of the metric. If the metric has several budgets, the one with the highest level is returned
+func (r Result) Verdict(metric vfrogapi.PerformanceBudgetMetric) (Verdict, bool) {
+	found := false
+	verdict := Verdict{}
+	for _, v := range r.Verdicts {
+		if v.Metric != metric {
+			continue
+		}
+		if !found || v.Level > verdict.Level {
+			verdict = v
+		}
+		found = true
+	}
+	return verdict, found
+}
+
+// Evaluate applies all budgets to the performance report. Budgets of unknown metrics are skipped.
+// A nil budgets results in no verdicts.
+func Evaluate(report vfrogapi.PerformanceReport, budgets *vfrogapi.PerformanceBudgets) Result {
+	return NewRules(budgets, nil).Evaluate(report)
+}
+
+// Compare compares the value against the budget. The budget thresholds are converted into the unit of the metric first.
+// Mode "above" (the default) fails values at or above the thresholds, mode "below" fails values at or below them.
+func Compare(value Value, b vfrogapi.PerformanceBudget) Verdict {
+	return compare(value, b, false)
+}
+
+// comparePreset compares like Google rates the Core Web Vitals, which the presets follow: values at the thresholds
+// pass. Mode "above" fails values above the thresholds, mode "below" values below them.
+func comparePreset(value Value, b vfrogapi.PerformanceBudget) Verdict {
+	return compare(value, b, true)
+}
+
+func compare(value Value, b vfrogapi.PerformanceBudget, exclusive bool) Verdict {
+	verdict := Verdict{
+		Metric:    b.Metric,
+		Value:     value,
+		Warning:   ThresholdValue(b.Metric, b.Warning),
+		Error:     ThresholdValue(b.Metric, b.Error),
+		Mode:      vfrogapi.Above,
+		Exclusive: exclusive,
+	}
+	if b.Mode != nil {
+		verdict.Mode = *b.Mode
+	}
+	v := value.Amount
+
+	// crossed reports whether the value is beyond the threshold in the direction of the mode
+	crossed := func(threshold Value) bool {
+		switch {
+		case verdict.Mode == vfrogapi.Above && exclusive:
+			return v > threshold.Amount
+		case verdict.Mode == vfrogapi.Above:
+			return v >= threshold.Amount
+		case exclusive:
+			return v < threshold.Amount
+		}
+		return v <= threshold.Amount
+	}
+	switch {
+	case !crossed(verdict.Warning):
+		verdict.Level = LevelOk
+	case !crossed(verdict.Error):
+		verdict.Level = LevelWarn
+	default:
+		verdict.Level = LevelFail
+	}
+	return verdict
+}
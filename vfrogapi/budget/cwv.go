@@ -0,0 +1,128 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"sort"
+)
+
+// Rating is the Core Web Vitals rating of a metric value
+type Rating string
+
+const (
+	// RatingGood means the value is at or below the good threshold
+	RatingGood Rating = "good"
+	// RatingNeedsImprovement means the value is between the good and the poor threshold
+	RatingNeedsImprovement Rating = "needs improvement"
+	// RatingPoor means the value is above the poor threshold
+	RatingPoor Rating = "poor"
+)
+
+// vital holds Google's thresholds of a Core Web Vital as budget thresholds (see MetricInfo.BudgetScale, the CLS is in
+// hundredths). Values up to Good are rated good, values up to Poor need improvement, everything above is poor. The
+// presets are built from the same thresholds and compared the same way, so they always agree with the assessment.
+type vital struct {
+	Metric vfrogapi.PerformanceBudgetMetric
+	Good   int32
+	Poor   int32
+}
+
+// coreWebVitals are the three Core Web Vitals. The max potential first input delay is the lab proxy of the field FID
+var coreWebVitals = []vital{
+	{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, Good: 2500, Poor: 4000},
+	{Metric: vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, Good: 10, Poor: 25},
+	{Metric: vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, Good: 100, Poor: 300},
+}
+
+// CoreWebVitals returns the metrics of the Core Web Vitals, in assessment order
+func CoreWebVitals() []vfrogapi.PerformanceBudgetMetric {
+	metrics := make([]vfrogapi.PerformanceBudgetMetric, 0, len(coreWebVitals))
+	for _, v := range coreWebVitals {
+		metrics = append(metrics, v.Metric)
+	}
+	return metrics
+}
+
+// budget returns the vital as budget warning on needs improvement and failing poor values. It is compared with
+// comparePreset, as values at the thresholds are still rated the better rating
+func (v vital) budget() vfrogapi.PerformanceBudget {
+	return vfrogapi.PerformanceBudget{Metric: v.Metric, Warning: v.Good, Error: v.Poor}
+}
+
+// rate rates the value like the presets judge it, see comparePreset
+func (v vital) rate(value Value) Rating {
+	switch comparePreset(value, v.budget()).Level {
+	case LevelOk:
+		return RatingGood
+	case LevelWarn:
+		return RatingNeedsImprovement
+	}
+	return RatingPoor
+}
+
+// VitalRating is the rating of a single Core Web Vital
+type VitalRating struct {
+	Metric vfrogapi.PerformanceBudgetMetric
+	Value  Value
+	Rating Rating
+}
+
+// Assessment is the Core Web Vitals assessment of a path on a device. Like Search Console, a path passes only if all
+// three vitals are rated good.
+type Assessment struct {
+	Path   string
+	Device vfrogapi.DeviceName
+	// Ratings of the vitals, ordered like CoreWebVitals
+	Ratings []VitalRating
+	Passed  bool
+}
+
+// Assess rates the Core Web Vitals per path and device. Reports of several countries (or runs) are combined by their
+// 75th percentile, as Google does with field data. Assessments are ordered by path and device.
+func Assess(reports []vfrogapi.PerformanceReport) []Assessment {
+	type pathDevice struct {
+		path   string
+		device vfrogapi.DeviceName
+	}
+	grouped := map[pathDevice][]vfrogapi.PerformanceReport{}
+	keys := make([]pathDevice, 0)
+	for _, report := range reports {
+		key := pathDevice{path: report.Path, device: report.Device.Name}
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+		}
+		grouped[key] = append(grouped[key], report)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].device < keys[j].device
+	})
+
+	assessments := make([]Assessment, 0, len(keys))
+	for _, key := range keys {
+		assessment := Assessment{Path: key.path, Device: key.device, Passed: true}
+		for _, v := range coreWebVitals {
+			amounts := make([]float64, 0, len(grouped[key]))
+			unit := UnitScore
+			for _, report := range grouped[key] {
+				value, _ := MetricValue(report, v.Metric)
+				amounts = append(amounts, value.Amount)
+				unit = value.Unit
+			}
+			sort.Float64s(amounts)
+			value := Value{Amount: percentile(amounts, 0.75), Unit: unit}
+			rating := VitalRating{Metric: v.Metric, Value: value, Rating: v.rate(value)}
+			assessment.Ratings = append(assessment.Ratings, rating)
+			assessment.Passed = assessment.Passed && rating.Rating == RatingGood
+		}
+		assessments = append(assessments, assessment)
+	}
+	return assessments
+}
+
+// String formats the rating. E.g. "2700ms (needs improvement)"
+func (r VitalRating) String() string {
+	return fmt.Sprintf("%s (%s)", r.Value, r.Rating)
+}
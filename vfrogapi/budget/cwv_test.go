@@ -0,0 +1,95 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"testing"
+)
+
+func TestPresetsAgreeWithAssess(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric vfrogapi.PerformanceBudgetMetric
+		report vfrogapi.PerformanceReport
+		want   Rating
+	}{
+		{"LCP 2500ms", vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, withVitals(2500, 0, 0), RatingGood},
+		{"LCP 2501ms", vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, withVitals(2501, 0, 0), RatingNeedsImprovement},
+		{"LCP 4000ms", vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, withVitals(4000, 0, 0), RatingNeedsImprovement},
+		{"LCP 4001ms", vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, withVitals(4001, 0, 0), RatingPoor},
+		{"CLS 0.1", vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, withVitals(0, 0.1, 0), RatingGood},
+		{"CLS 0.101", vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, withVitals(0, 0.101, 0), RatingNeedsImprovement},
+		{"CLS 0.109", vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, withVitals(0, 0.109, 0), RatingNeedsImprovement},
+		{"CLS 0.25", vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, withVitals(0, 0.25, 0), RatingNeedsImprovement},
+		{"CLS 0.251", vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, withVitals(0, 0.251, 0), RatingPoor},
+		{"CLS 0.259", vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, withVitals(0, 0.259, 0), RatingPoor},
+		{"FID 100ms", vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, withVitals(0, 0, 100), RatingGood},
+		{"FID 101ms", vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, withVitals(0, 0, 101), RatingNeedsImprovement},
+		{"FID 300ms", vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, withVitals(0, 0, 300), RatingNeedsImprovement},
+		{"FID 301ms", vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, withVitals(0, 0, 301), RatingPoor},
+	}
+	good, _ := Preset("google-cwv-good")
+	needsImprovement, _ := Preset("google-cwv-needs-improvement")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rating VitalRating
+			for _, r := range Assess([]vfrogapi.PerformanceReport{tt.report})[0].Ratings {
+				if r.Metric == tt.metric {
+					rating = r
+				}
+			}
+			if rating.Rating != tt.want {
+				t.Errorf("Assess rated %s, want %s", rating, tt.want)
+			}
+
+			verdict, _ := Evaluate(tt.report, needsImprovement).Verdict(tt.metric)
+			wantLevel := map[Rating]Level{RatingGood: LevelOk, RatingNeedsImprovement: LevelWarn, RatingPoor: LevelFail}[tt.want]
+			if verdict.Level != wantLevel {
+				t.Errorf("google-cwv-needs-improvement judged %s as %s, want %s", verdict, verdict.Level, wantLevel)
+			}
+
+			verdict, _ = Evaluate(tt.report, good).Verdict(tt.metric)
+			if passed := verdict.Level == LevelOk; passed != (tt.want == RatingGood) {
+				t.Errorf("google-cwv-good judged %s as %s, but Assess rated it %s", verdict, verdict.Level, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetsPassValuesAtTheThresholds(t *testing.T) {
+	lcp := vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs
+	needsImprovement, _ := Preset("google-cwv-needs-improvement")
+	local := &Config{
+		Budgets: []vfrogapi.PerformanceBudget{{Metric: vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, Warning: 100, Error: 300}},
+		Tiers:   []Tier{{Name: "target", Effect: TierEffectError, Preset: "google-cwv-good"}},
+	}
+	if err := local.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	rules := NewRules(needsImprovement, local)
+
+	tests := []struct {
+		name   string
+		report vfrogapi.PerformanceReport
+		metric vfrogapi.PerformanceBudgetMetric
+		want   string
+		level  Level
+	}{
+		{"preset at the threshold", withVitals(2500, 0, 0), lcp, "2500ms ≤ 2500ms", LevelOk},
+		{"preset above the threshold", withVitals(2501, 0, 0), lcp, "2501ms > 2500ms", LevelWarn},
+		{"preset at the error threshold", withVitals(4000, 0, 0), lcp, "4000ms > 2500ms", LevelWarn},
+		{"local budget at the threshold", withVitals(0, 0, 100), vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, "100ms ≥ 100ms", LevelWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, _ := rules.Evaluate(tt.report).Verdict(tt.metric)
+			if verdict.Level != tt.level || verdict.String() != tt.want {
+				t.Errorf("verdict = %q (%s), want %q (%s)", verdict, verdict.Level, tt.want, tt.level)
+			}
+		})
+	}
+
+	tier := rules.Evaluate(withVitals(2500, 0.1, 100)).TierVerdicts[0]
+	if tier.Level != LevelOk {
+		t.Errorf("tier with the google-cwv-good preset judged LCP 2500ms, CLS 0.1 and FID 100ms as %s, want ok", tier.Level)
+	}
+}
@@ -0,0 +1,546 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled rule expression. Expressions are evaluated against a single performance report and must result
+// in a boolean. They can not call functions or loop, so they are safe to evaluate from untrusted config files.
+//
+// Grammar:
+//
+//	or      = and { ("||" | "or") and }
+//	and     = not { ("&&" | "and") not }
+//	not     = ("!" | "not") not | compare
+//	compare = sum [ ("<" | "<=" | ">" | ">=" | "==" | "!=") sum ]
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/") unary }
+//	unary   = "-" unary | "(" or ")" | number | "true" | "false" | identifier
+//
+// Numbers may have a unit suffix: "ms", "s" (x1000), "kb" (x1000), "mb" (x1000000). Identifiers are listed in Idents.
+type Expr struct {
+	src    string
+	root   exprNode
+	idents []string
+}
+
+// exprType is the type of expression node
+type exprType int
+
+const (
+	numberType exprType = iota
+	boolType
+)
+
+func (t exprType) String() string {
+	if t == boolType {
+		return "bool"
+	}
+	return "number"
+}
+
+// exprValue is the result of an expression node
+type exprValue struct {
+	num float64
+	b   bool
+}
+
+type exprNode interface {
+	typ() exprType
+	eval(report vfrogapi.PerformanceReport) exprValue
+}
+
+// ExprIdent is an identifier usable in expressions
+type ExprIdent struct {
+	Name        string
+	Description string
+	isBool      bool
+	get         func(report vfrogapi.PerformanceReport) exprValue
+}
+
+func numberIdent(name, description string, get func(report vfrogapi.PerformanceReport) float64) ExprIdent {
+	return ExprIdent{Name: name, Description: description, get: func(report vfrogapi.PerformanceReport) exprValue {
+		return exprValue{num: get(report)}
+	}}
+}
+
+func boolIdent(name, description string, get func(report vfrogapi.PerformanceReport) bool) ExprIdent {
+	return ExprIdent{Name: name, Description: description, isBool: true, get: func(report vfrogapi.PerformanceReport) exprValue {
+		return exprValue{b: get(report)}
+	}}
+}
+
+func metricIdent(name string, metric vfrogapi.PerformanceBudgetMetric) ExprIdent {
+	info, _ := Info(metric)
+	return numberIdent(name, fmt.Sprintf("%s in %s", info.Name, unitName(info.Unit)), func(report vfrogapi.PerformanceReport) float64 {
+		value, _ := MetricValue(report, metric)
+		return value.Amount
+	})
+}
+
+func unitName(u Unit) string {
+	switch u {
+	case UnitMilliseconds:
+		return "milliseconds"
+	case UnitBytes:
+		return "bytes"
+	}
+	return "score"
+}
+
+func derefBool(b *bool) bool {
+	return b != nil && *b
+}
+
+// Idents are all identifiers usable in expressions. Every budget metric apart from computed ones can be used by its name
+// and abbreviation.
+var Idents = func() []ExprIdent {
+	idents := make([]ExprIdent, 0)
+	for _, info := range metricInfos {
+		if info.Computed {
+			continue
+		}
+		idents = append(idents, metricIdent(string(info.Metric), info.Metric))
+		idents = append(idents, metricIdent(strings.ToLower(info.Short), info.Metric))
+	}
+	idents = append(idents,
+		boolIdent("largest_contentful_paint.lazy_loaded", "The LCP element is lazy loaded", func(report vfrogapi.PerformanceReport) bool {
+			return derefBool(report.LargestContentfulPaint.LazyLoaded)
+		}),
+		boolIdent("largest_contentful_paint.image_preloaded", "The LCP image is preloaded", func(report vfrogapi.PerformanceReport) bool {
+			return derefBool(report.LargestContentfulPaint.ImagePreloaded)
+		}),
+		numberIdent("big_payloads.count", "Number of big payloads", func(report vfrogapi.PerformanceReport) float64 {
+			return float64(len(report.BigPayloads.Payloads))
+		}),
+		numberIdent("network_requests.count", "Number of network requests", func(report vfrogapi.PerformanceReport) float64 {
+			return float64(len(report.NetworkRequests))
+		}),
+		numberIdent("network_requests.total_bytes", "Sum of all network request sizes in bytes", func(report vfrogapi.PerformanceReport) float64 {
+			total := 0.0
+			for _, r := range report.NetworkRequests {
+				total += float64(r.SizeByte)
+			}
+			return total
+		}),
+		numberIdent("network_requests.max_bytes", "Size of the biggest network request in bytes", func(report vfrogapi.PerformanceReport) float64 {
+			max := 0.0
+			for _, r := range report.NetworkRequests {
+				if float64(r.SizeByte) > max {
+					max = float64(r.SizeByte)
+				}
+			}
+			return max
+		}),
+		numberIdent("network_requests.max_load_time_ms", "Load time of the slowest network request in milliseconds", func(report vfrogapi.PerformanceReport) float64 {
+			max := 0.0
+			for _, r := range report.NetworkRequests {
+				if float64(r.LoadTimeMs) > max {
+					max = float64(r.LoadTimeMs)
+				}
+			}
+			return max
+		}),
+		numberIdent("network_requests.end_time_ms", "Time the last network request finished in milliseconds", func(report vfrogapi.PerformanceReport) float64 {
+			end := 0.0
+			for _, r := range report.NetworkRequests {
+				if float64(r.StartTimeMs+r.LoadTimeMs) > end {
+					end = float64(r.StartTimeMs + r.LoadTimeMs)
+				}
+			}
+			return end
+		}),
+	)
+	return idents
+}()
+
+func lookupIdent(name string) (ExprIdent, bool) {
+	for _, ident := range Idents {
+		if ident.Name == name {
+			return ident, true
+		}
+	}
+	return ExprIdent{}, false
+}
+
+// ParseExpr compiles and type checks the expression
+func ParseExpr(src string) (*Expr, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, idents: map[string]struct{}{}}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	}
+	if root.typ() != boolType {
+		return nil, fmt.Errorf("expression must result in a bool, got a %s", root.typ())
+	}
+
+	idents := make([]string, 0, len(p.idents))
+	for ident := range p.idents {
+		idents = append(idents, ident)
+	}
+	sort.Strings(idents)
+	return &Expr{src: src, root: root, idents: idents}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against the performance report
+func (e *Expr) Eval(report vfrogapi.PerformanceReport) bool {
+	return e.root.eval(report).b
+}
+
+// Values returns the values of all identifiers used in the expression, formatted for display
+func (e *Expr) Values(report vfrogapi.PerformanceReport) map[string]string {
+	values := map[string]string{}
+	for _, name := range e.idents {
+		ident, _ := lookupIdent(name)
+		v := ident.get(report)
+		if ident.isBool {
+			values[name] = strconv.FormatBool(v.b)
+		} else {
+			values[name] = strconv.FormatFloat(v.num, 'f', -1, 64)
+		}
+	}
+	return values
+}
+
+//
+// Lexer
+
+type exprToken struct {
+	text string
+	pos  int
+	num  *float64
+}
+
+var unitSuffixes = map[string]float64{"ms": 1, "s": 1000, "kb": 1000, "mb": 1000 * 1000}
+
+func lexExpr(src string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	runes := []rune(src)
+	for k := 0; k < len(runes); {
+		r := runes[k]
+		switch {
+		case unicode.IsSpace(r):
+			k++
+		case unicode.IsDigit(r) || (r == '.' && k+1 < len(runes) && unicode.IsDigit(runes[k+1])):
+			start := k
+			for k < len(runes) && (unicode.IsDigit(runes[k]) || runes[k] == '.') {
+				k++
+			}
+			num, err := strconv.ParseFloat(string(runes[start:k]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", string(runes[start:k]), start)
+			}
+			suffixStart := k
+			for k < len(runes) && unicode.IsLetter(runes[k]) {
+				k++
+			}
+			if suffix := strings.ToLower(string(runes[suffixStart:k])); suffix != "" {
+				factor, ok := unitSuffixes[suffix]
+				if !ok {
+					return nil, fmt.Errorf("unknown unit %q at position %d", suffix, suffixStart)
+				}
+				num *= factor
+			}
+			tokens = append(tokens, exprToken{text: string(runes[start:k]), pos: start, num: &num})
+		case unicode.IsLetter(r) || r == '_':
+			start := k
+			for k < len(runes) && (unicode.IsLetter(runes[k]) || unicode.IsDigit(runes[k]) || runes[k] == '_' || runes[k] == '.') {
+				k++
+			}
+			tokens = append(tokens, exprToken{text: string(runes[start:k]), pos: start})
+		default:
+			two := ""
+			if k+1 < len(runes) {
+				two = string(runes[k : k+2])
+			}
+			switch two {
+			case "<=", ">=", "==", "!=", "&&", "||":
+				tokens = append(tokens, exprToken{text: two, pos: k})
+				k += 2
+				continue
+			}
+			if !strings.ContainsRune("()+-*/<>!", r) {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, k)
+			}
+			tokens = append(tokens, exprToken{text: string(r), pos: k})
+			k++
+		}
+	}
+	return tokens, nil
+}
+
+//
+// Parser
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	idents map[string]struct{}
+}
+
+func (p *exprParser) peek(texts ...string) (string, bool) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].num != nil {
+		return "", false
+	}
+	for _, text := range texts {
+		if p.tokens[p.pos].text == text {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseLogical([]string{"||", "or"}, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseLogical([]string{"&&", "and"}, p.parseNot)
+}
+
+func (p *exprParser) parseLogical(ops []string, next func() (exprNode, error)) (exprNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peek(ops...)
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != boolType || right.typ() != boolType {
+			return nil, fmt.Errorf("%q needs bool operands", op)
+		}
+		left = logicalNode{or: op == "||" || op == "or", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if op, ok := p.peek("!", "not"); ok {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != boolType {
+			return nil, fmt.Errorf("%q needs a bool operand", op)
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.peek("<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ() != right.typ() {
+		return nil, fmt.Errorf("can not compare %s with %s", left.typ(), right.typ())
+	}
+	if left.typ() == boolType && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("%q needs number operands", op)
+	}
+	return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	return p.parseArithmetic([]string{"+", "-"}, p.parseProduct)
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	return p.parseArithmetic([]string{"*", "/"}, p.parseUnary)
+}
+
+func (p *exprParser) parseArithmetic(ops []string, next func() (exprNode, error)) (exprNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peek(ops...)
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != numberType || right.typ() != numberType {
+			return nil, fmt.Errorf("%q needs number operands", op)
+		}
+		left = arithmeticNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	if t.num != nil {
+		return constNode{v: exprValue{num: *t.num}, t: numberType}, nil
+	}
+	switch t.text {
+	case "-":
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != numberType {
+			return nil, fmt.Errorf("'-' needs a number operand")
+		}
+		return arithmeticNode{op: "-", left: constNode{t: numberType}, right: operand}, nil
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.peek(")"); !ok {
+			return nil, fmt.Errorf("missing ')' for '(' at position %d", t.pos)
+		}
+		p.pos++
+		return inner, nil
+	case "true", "false":
+		return constNode{v: exprValue{b: t.text == "true"}, t: boolType}, nil
+	}
+
+	ident, ok := lookupIdent(t.text)
+	if !ok {
+		return nil, fmt.Errorf("unknown identifier %q at position %d", t.text, t.pos)
+	}
+	p.idents[ident.Name] = struct{}{}
+	return identNode{ident: ident}, nil
+}
+
+//
+// Nodes
+
+type constNode struct {
+	v exprValue
+	t exprType
+}
+
+func (n constNode) typ() exprType                               { return n.t }
+func (n constNode) eval(_ vfrogapi.PerformanceReport) exprValue { return n.v }
+
+type identNode struct {
+	ident ExprIdent
+}
+
+func (n identNode) typ() exprType {
+	if n.ident.isBool {
+		return boolType
+	}
+	return numberType
+}
+func (n identNode) eval(report vfrogapi.PerformanceReport) exprValue { return n.ident.get(report) }
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n notNode) typ() exprType { return boolType }
+func (n notNode) eval(report vfrogapi.PerformanceReport) exprValue {
+	return exprValue{b: !n.operand.eval(report).b}
+}
+
+type logicalNode struct {
+	or          bool
+	left, right exprNode
+}
+
+func (n logicalNode) typ() exprType { return boolType }
+func (n logicalNode) eval(report vfrogapi.PerformanceReport) exprValue {
+	left := n.left.eval(report).b
+	if n.or {
+		return exprValue{b: left || n.right.eval(report).b}
+	}
+	return exprValue{b: left && n.right.eval(report).b}
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n compareNode) typ() exprType { return boolType }
+func (n compareNode) eval(report vfrogapi.PerformanceReport) exprValue {
+	left, right := n.left.eval(report), n.right.eval(report)
+	if n.left.typ() == boolType {
+		if n.op == "==" {
+			return exprValue{b: left.b == right.b}
+		}
+		return exprValue{b: left.b != right.b}
+	}
+	switch n.op {
+	case "<":
+		return exprValue{b: left.num < right.num}
+	case "<=":
+		return exprValue{b: left.num <= right.num}
+	case ">":
+		return exprValue{b: left.num > right.num}
+	case ">=":
+		return exprValue{b: left.num >= right.num}
+	case "==":
+		return exprValue{b: left.num == right.num}
+	}
+	return exprValue{b: left.num != right.num}
+}
+
+type arithmeticNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n arithmeticNode) typ() exprType { return numberType }
+func (n arithmeticNode) eval(report vfrogapi.PerformanceReport) exprValue {
+	left, right := n.left.eval(report).num, n.right.eval(report).num
+	switch n.op {
+	case "+":
+		return exprValue{num: left + right}
+	case "-":
+		return exprValue{num: left - right}
+	case "*":
+		return exprValue{num: left * right}
+	}
+	// Division by zero results in 0 to keep rules evaluable
+	if right == 0 {
+		return exprValue{}
+	}
+	return exprValue{num: left / right}
+}
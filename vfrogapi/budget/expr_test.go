@@ -0,0 +1,113 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func exprReport() vfrogapi.PerformanceReport {
+	lazy := true
+	return vfrogapi.PerformanceReport{
+		LargestContentfulPaint: vfrogapi.LargestContentfulPaint{ValueMs: 3200, LazyLoaded: &lazy},
+		CumulativeLayoutShift:  vfrogapi.CumulativeLayoutShift{Value: 0.1},
+		TotalBlockingTimeMs:    250,
+		BigPayloads:            vfrogapi.BigPayloads{Payloads: []vfrogapi.Payload{{TotalBytes: 2000000}}, TotalBytes: 2000000},
+		NetworkRequests: []vfrogapi.NetworkRequest{
+			{StartTimeMs: 0, LoadTimeMs: 300, SizeByte: 1500},
+			{StartTimeMs: 200, LoadTimeMs: 900, SizeByte: 600000},
+		},
+	}
+}
+
+func TestParseExprEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"lcp > 3s", true},
+		{"lcp >= 3200", true},
+		{"lcp < 3200ms", false},
+		{"largest_contentful_paint_ms == lcp", true},
+		{"cls <= 0.1", true},
+		{"cls < .1", false},
+		{"largest_contentful_paint.lazy_loaded", true},
+		{"!largest_contentful_paint.lazy_loaded", false},
+		{"not largest_contentful_paint.image_preloaded", true},
+		{"largest_contentful_paint.lazy_loaded && lcp > 2500", true},
+		{"largest_contentful_paint.lazy_loaded and lcp > 4000 or tbt > 200", true},
+		{"largest_contentful_paint.lazy_loaded and (lcp > 4000 or tbt > 300)", false},
+		{"largest_contentful_paint.lazy_loaded == true", true},
+		{"largest_contentful_paint.image_preloaded != false", false},
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"10 - 4 - 3 == 3", true},
+		{"-lcp + 3200 == 0", true},
+		{"lcp / 0 == 0", true},
+		{"big_payloads.count == 1 && bp >= 2mb", true},
+		{"network_requests.count == 2", true},
+		{"network_requests.total_bytes == 601500", true},
+		{"network_requests.max_bytes > 500kb", true},
+		{"network_requests.max_load_time_ms == 900", true},
+		{"network_requests.end_time_ms == 1100", true},
+		{"true || false", true},
+		{"false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := ParseExpr(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpr() error = %v", err)
+			}
+			if got := expr.Eval(exprReport()); got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "unexpected end of expression"},
+		{"lcp", "must result in a bool"},
+		{"lcp + 1", "must result in a bool"},
+		{"lcp > 2500 &&", "unexpected end of expression"},
+		{"lcp > largest_contentful_paint.lazy_loaded", "can not compare number with bool"},
+		{"largest_contentful_paint.lazy_loaded < true", `"<" needs number operands`},
+		{"lcp && true", `"&&" needs bool operands`},
+		{"!lcp", `"!" needs a bool operand`},
+		{"true + 1 == 2", `"+" needs number operands`},
+		{"-true", "'-' needs a number operand"},
+		{"(lcp > 1", "missing ')'"},
+		{"lcp > 1)", `unexpected ")" at position 7`},
+		{"1 < 2 < 3", `unexpected "<"`},
+		{"foo > 1", `unknown identifier "foo" at position 0`},
+		{"performance_score > 50", `unknown identifier "performance_score"`},
+		{"lcp > 2h", `unknown unit "h"`},
+		{"lcp > 1.2.3", "invalid number"},
+		{"lcp > 1 % 2", "unexpected character '%'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := ParseExpr(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseExpr() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprValues(t *testing.T) {
+	expr, err := ParseExpr("lcp > 2500 && largest_contentful_paint.lazy_loaded && lcp < 5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"lcp": "3200", "largest_contentful_paint.lazy_loaded": "true"}
+	if got := expr.Values(exprReport()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,304 @@
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"math"
+	"os"
+	"strings"
+)
+
+// LighthouseBudget is a single entry of a Lighthouse budget.json. See
+// https://github.com/GoogleChrome/lighthouse/blob/main/docs/performance-budgets.md
+type LighthouseBudget struct {
+	// Path the budget applies to. '*' matches any characters, '$' anchors the end, otherwise it is a prefix.
+	// Empty applies to all paths
+	Path           string               `json:"path,omitempty"`
+	Options        json.RawMessage      `json:"options,omitempty"`
+	Timings        []LighthouseTiming   `json:"timings,omitempty"`
+	ResourceSizes  []LighthouseResource `json:"resourceSizes,omitempty"`
+	ResourceCounts []LighthouseResource `json:"resourceCounts,omitempty"`
+}
+
+// LighthouseTiming budgets a timing metric in milliseconds. The cumulative layout shift is unitless
+type LighthouseTiming struct {
+	Metric string  `json:"metric"`
+	Budget float64 `json:"budget"`
+}
+
+// LighthouseResource budgets the size in KiB or count of a resource type
+type LighthouseResource struct {
+	ResourceType string  `json:"resourceType"`
+	Budget       float64 `json:"budget"`
+}
+
+// lighthouseTimings maps the Lighthouse timing metrics to ours. first-cpu-idle and estimated-input-latency have
+// no counterpart
+var lighthouseTimings = map[string]vfrogapi.PerformanceBudgetMetric{
+	"first-contentful-paint":   vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs,
+	"first-meaningful-paint":   vfrogapi.PerformanceBudgetMetricFirstMeaningfulPaintMs,
+	"largest-contentful-paint": vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs,
+	"speed-index":              vfrogapi.PerformanceBudgetMetricSpeedIndexMs,
+	"interactive":              vfrogapi.PerformanceBudgetMetricInteractiveMs,
+	"total-blocking-time":      vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs,
+	"max-potential-fid":        vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs,
+	"cumulative-layout-shift":  vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift,
+}
+
+// ReadLighthouseBudgets reads a Lighthouse budget.json
+func ReadLighthouseBudgets(fileName string) ([]LighthouseBudget, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+	budgets := make([]LighthouseBudget, 0)
+	err = json.Unmarshal(raw, &budgets)
+	if err != nil {
+		return nil, fmt.Errorf("could not json unmarshal %q: %w", fileName, err)
+	}
+	return budgets, nil
+}
+
+// FromLighthouse converts Lighthouse budgets. Entries for all paths ('/', '/*' or none) before the first path specific
+// one become the performance budgets, the others become local overrides matching their path. Lighthouse only fails,
+// so warning and error threshold are the same. Everything which can not be represented is returned as dropped.
+//
+// Lighthouse applies only the last matching entry, while overrides are layered per metric. Metrics of the budgets for
+// all paths which a path specific entry does not budget are reported, as they still apply to its path.
+func FromLighthouse(budgets []LighthouseBudget) (*vfrogapi.PerformanceBudgets, *Config, []string) {
+	performanceBudgets := &vfrogapi.PerformanceBudgets{Budgets: make([]vfrogapi.PerformanceBudget, 0), Description: "Imported from Lighthouse budget.json"}
+	cfg := &Config{}
+	dropped := make([]string, 0)
+
+	pathSpecific := false
+	for k, lb := range budgets {
+		where := fmt.Sprintf("entry %d (path %q)", k, lb.Path)
+		converted := make([]vfrogapi.PerformanceBudget, 0, len(lb.Timings))
+		for _, t := range lb.Timings {
+			metric, ok := lighthouseTimings[t.Metric]
+			if !ok {
+				dropped = append(dropped, fmt.Sprintf("%s: timing %q has no VitalFrog metric", where, t.Metric))
+				continue
+			}
+			threshold, exact := fromLighthouseThreshold(metric, t.Budget)
+			if !exact {
+				dropped = append(dropped, fmt.Sprintf("%s: timing %q budget %g becomes the threshold %s, which fails values at or above it while Lighthouse only fails values above the budget",
+					where, t.Metric, t.Budget, ThresholdValue(metric, threshold)))
+			}
+			converted = append(converted, vfrogapi.PerformanceBudget{Metric: metric, Warning: threshold, Error: threshold})
+		}
+		for _, r := range lb.ResourceSizes {
+			dropped = append(dropped, fmt.Sprintf("%s: resource size of %q, resource sizes can not be budgeted. %s only counts the big payloads",
+				where, r.ResourceType, vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes))
+		}
+		for _, r := range lb.ResourceCounts {
+			dropped = append(dropped, fmt.Sprintf("%s: resource count of %q, resource counts can not be budgeted", where, r.ResourceType))
+		}
+		if len(lb.Options) > 0 {
+			dropped = append(dropped, fmt.Sprintf("%s: options %s", where, string(lb.Options)))
+		}
+
+		glob, ok := lighthousePathGlob(lb.Path)
+		if !ok {
+			dropped = append(dropped, fmt.Sprintf("%s: path can not be matched, '?' would be a wildcard. Skipping the entry", where))
+			continue
+		}
+		if glob == "" && !pathSpecific {
+			performanceBudgets.Budgets = layerBudgets(performanceBudgets.Budgets, converted)
+			continue
+		}
+		pathSpecific = true
+		if glob == "" {
+			glob = "**"
+		}
+		for _, b := range performanceBudgets.Budgets {
+			if !budgetsMetric(converted, b.Metric) {
+				dropped = append(dropped, fmt.Sprintf("%s: does not budget %s, so the budget for all paths still applies to it", where, b.Metric))
+			}
+		}
+		cfg.Overrides = append(cfg.Overrides, Override{Name: lb.Path, Match: Match{Paths: []string{glob}}, Budgets: converted})
+	}
+	return performanceBudgets, cfg, dropped
+}
+
+// ToLighthouse converts the performance budgets layered with the local budgets into Lighthouse budgets. Both may be
+// nil. Every override becomes an entry per path glob which includes the budgets for all paths, as Lighthouse applies
+// only the last matching entry. Lighthouse only fails, so the error thresholds are used. Everything which can not be
+// represented is returned as dropped.
+func ToLighthouse(performanceBudgets *vfrogapi.PerformanceBudgets, local *Config) ([]LighthouseBudget, []string) {
+	dropped := make([]string, 0)
+	all := make([]Rule, 0)
+	if performanceBudgets != nil {
+		all = layerRules(all, performanceBudgets.Budgets, isPreset(performanceBudgets))
+	}
+	if local != nil {
+		all = layerRules(all, local.Budgets, false)
+		if len(local.Rules) > 0 {
+			dropped = append(dropped, fmt.Sprintf("%d rules, Lighthouse has no expressions", len(local.Rules)))
+		}
+		if len(local.Regressions) > 0 {
+			dropped = append(dropped, fmt.Sprintf("%d regressions, Lighthouse has no baselines", len(local.Regressions)))
+		}
+		if len(local.Tiers) > 0 {
+			dropped = append(dropped, fmt.Sprintf("%d tiers, only the layered budgets are converted", len(local.Tiers)))
+		}
+	}
+
+	lighthouseBudgets := []LighthouseBudget{toLighthouseBudget("/", all, "budgets for all paths", &dropped)}
+	if local == nil {
+		return lighthouseBudgets, dropped
+	}
+	for _, o := range local.Overrides {
+		where := fmt.Sprintf("override %s", o.Name)
+		if len(o.Devices) > 0 || len(o.Countries) > 0 {
+			dropped = append(dropped, fmt.Sprintf("%s: Lighthouse budgets can not match devices or countries. Skipping the override", where))
+			continue
+		}
+		if len(o.Paths) == 0 {
+			lighthouseBudgets = append(lighthouseBudgets, toLighthouseBudget("/", layerRules(all, o.Budgets, false), where, &dropped))
+			continue
+		}
+		for _, glob := range o.Paths {
+			path, ok := lighthousePath(glob)
+			if !ok {
+				dropped = append(dropped, fmt.Sprintf("%s: path glob %q uses '*' or '?' within a segment, which Lighthouse can not match. Skipping the glob", where, glob))
+				continue
+			}
+			lighthouseBudgets = append(lighthouseBudgets, toLighthouseBudget(path, layerRules(all, o.Budgets, false), where, &dropped))
+		}
+	}
+	return lighthouseBudgets, dropped
+}
+
+func toLighthouseBudget(path string, rules []Rule, where string, dropped *[]string) LighthouseBudget {
+	lb := LighthouseBudget{Path: path}
+	for _, rule := range rules {
+		b := rule.Budget
+		drop := func(format string, a ...interface{}) {
+			*dropped = append(*dropped, fmt.Sprintf("%s: %s %s", where, b.Metric, fmt.Sprintf(format, a...)))
+		}
+		if b.Mode != nil && *b.Mode == vfrogapi.Below {
+			drop("has mode %q, Lighthouse only fails values above the budget", vfrogapi.Below)
+			continue
+		}
+		if b.Warning != b.Error {
+			drop("warning threshold %s is dropped, Lighthouse only fails", ThresholdValue(b.Metric, b.Warning))
+		}
+
+		timing := ""
+		for name, metric := range lighthouseTimings {
+			if metric == b.Metric {
+				timing = name
+			}
+		}
+		switch {
+		case b.Metric == vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes:
+			drop("only counts the big payloads, Lighthouse resource sizes count every resource")
+			continue
+		case timing == "":
+			drop("has no Lighthouse timing")
+			continue
+		}
+		lighthouseBudget, exact := toLighthouseThreshold(b.Metric, b.Error, rule.Exclusive)
+		if !exact {
+			drop("error threshold %s becomes the Lighthouse budget %g, which passes a value of exactly %g", ThresholdValue(b.Metric, b.Error), lighthouseBudget, lighthouseBudget)
+		}
+		lb.Timings = append(lb.Timings, LighthouseTiming{Metric: timing, Budget: lighthouseBudget})
+	}
+	return lb
+}
+
+// fromLighthouseThreshold converts a Lighthouse budget into a threshold. Lighthouse fails values above the budget,
+// budgets fail values at or above the threshold. Timings are integral milliseconds, so their threshold is one above the
+// budget and converts exactly. The CLS is fractional and budgeted in hundredths, so its threshold is the closest
+// hundredth, which also fails a CLS of exactly the budget. Returns false if the conversion is not exact.
+func fromLighthouseThreshold(metric vfrogapi.PerformanceBudgetMetric, budget float64) (int32, bool) {
+	if metric == vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift {
+		return int32(math.Round(budget * 100)), false
+	}
+	return int32(math.Floor(budget)) + 1, true
+}
+
+// toLighthouseThreshold converts a threshold into a Lighthouse budget, the inverse of fromLighthouseThreshold.
+// Exclusive thresholds (see Rule) pass values at the threshold like Lighthouse and are kept. Returns false if the
+// Lighthouse budget passes a CLS of exactly the threshold, which the budget fails.
+func toLighthouseThreshold(metric vfrogapi.PerformanceBudgetMetric, threshold int32, exclusive bool) (float64, bool) {
+	value := ThresholdValue(metric, threshold).Amount
+	switch {
+	case exclusive:
+		return value, true
+	case metric == vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:
+		return value, false
+	}
+	return value - 1, true
+}
+
+// lighthousePathGlob converts a Lighthouse path pattern into a path glob. Empty means all paths. Returns false for
+// patterns with '?', as it is a wildcard in globs
+func lighthousePathGlob(path string) (string, bool) {
+	if strings.Contains(path, "?") {
+		return "", false
+	}
+	if path == "" || path == "/" || path == "/*" {
+		return "", true
+	}
+	glob := strings.ReplaceAll(path, "*", "**")
+	if strings.HasSuffix(glob, "$") {
+		return strings.TrimSuffix(glob, "$"), true
+	}
+	if !strings.HasSuffix(glob, "**") {
+		glob += "**"
+	}
+	return glob, true
+}
+
+// lighthousePath is the inverse of lighthousePathGlob. Returns false for globs with '*' or '?' matching within a path
+// segment only
+func lighthousePath(glob string) (string, bool) {
+	if strings.Contains(glob, "?") || strings.Contains(strings.ReplaceAll(glob, "**", ""), "*") {
+		return "", false
+	}
+	if strings.HasSuffix(glob, "**") {
+		path := strings.ReplaceAll(strings.TrimSuffix(glob, "**"), "**", "*")
+		if path == "" {
+			path = "/"
+		}
+		return path, true
+	}
+	return strings.ReplaceAll(glob, "**", "*") + "$", true
+}
+
+// layerBudgets returns the budgets with the layer replacing the budgets of the same metric, like Rules.For layers them
+func layerBudgets(budgets, layer []vfrogapi.PerformanceBudget) []vfrogapi.PerformanceBudget {
+	layered := make([]vfrogapi.PerformanceBudget, 0, len(budgets)+len(layer))
+	for _, b := range budgets {
+		if !budgetsMetric(layer, b.Metric) {
+			layered = append(layered, b)
+		}
+	}
+	return append(layered, layer...)
+}
+
+// layerRules is layerBudgets keeping whether the budgets of a layer are exclusive
+func layerRules(rules []Rule, layer []vfrogapi.PerformanceBudget, exclusive bool) []Rule {
+	layered := make([]Rule, 0, len(rules)+len(layer))
+	for _, rule := range rules {
+		if !budgetsMetric(layer, rule.Budget.Metric) {
+			layered = append(layered, rule)
+		}
+	}
+	for _, b := range layer {
+		layered = append(layered, Rule{Budget: b, Exclusive: exclusive})
+	}
+	return layered
+}
+
+func budgetsMetric(budgets []vfrogapi.PerformanceBudget, metric vfrogapi.PerformanceBudgetMetric) bool {
+	for _, b := range budgets {
+		if b.Metric == metric {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLighthouseThresholds(t *testing.T) {
+	lcp := vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs
+	cls := vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift
+	tests := []struct {
+		metric    vfrogapi.PerformanceBudgetMetric
+		budget    float64
+		threshold int32
+		// exact is whether the import is exact, the export of the threshold is then as well
+		exact bool
+		back  float64
+	}{
+		{lcp, 2500, 2501, true, 2500},
+		{lcp, 2500.5, 2501, true, 2500},
+		{vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs, 0, 1, true, 0},
+		// Budgets fail a CLS of exactly the threshold, Lighthouse passes it
+		{cls, 0.1, 10, false, 0.1},
+		{cls, 0.25, 25, false, 0.25},
+		{cls, 0.125, 13, false, 0.13},
+	}
+	for _, tt := range tests {
+		threshold, exact := fromLighthouseThreshold(tt.metric, tt.budget)
+		if threshold != tt.threshold || exact != tt.exact {
+			t.Errorf("fromLighthouseThreshold(%s, %g) = %d, %v, want %d, %v", tt.metric, tt.budget, threshold, exact, tt.threshold, tt.exact)
+		}
+		if back, exact := toLighthouseThreshold(tt.metric, threshold, false); back != tt.back || exact != tt.exact {
+			t.Errorf("toLighthouseThreshold(%s, %d) = %g, %v, want %g, %v", tt.metric, threshold, back, exact, tt.back, tt.exact)
+		}
+	}
+
+	// The presets pass values at their thresholds like Lighthouse, so they convert exactly
+	for _, tt := range []struct {
+		metric    vfrogapi.PerformanceBudgetMetric
+		threshold int32
+		want      float64
+	}{{lcp, 2500, 2500}, {cls, 10, 0.1}, {cls, 25, 0.25}} {
+		if got, exact := toLighthouseThreshold(tt.metric, tt.threshold, true); got != tt.want || !exact {
+			t.Errorf("toLighthouseThreshold(%s, %d, exclusive) = %g, %v, want %g, true", tt.metric, tt.threshold, got, exact, tt.want)
+		}
+	}
+}
+
+func TestLighthousePaths(t *testing.T) {
+	tests := []struct {
+		path string
+		glob string
+		ok   bool
+	}{
+		{"", "", true},
+		{"/", "", true},
+		{"/*", "", true},
+		{"/blog", "/blog**", true},
+		{"/blog/*/comments", "/blog/**/comments**", true},
+		{"/checkout$", "/checkout", true},
+		{"/search?q=1", "", false},
+	}
+	for _, tt := range tests {
+		glob, ok := lighthousePathGlob(tt.path)
+		if glob != tt.glob || ok != tt.ok {
+			t.Errorf("lighthousePathGlob(%q) = %q, %v, want %q, %v", tt.path, glob, ok, tt.glob, tt.ok)
+		}
+		if !ok || glob == "" {
+			continue
+		}
+		if path, ok := lighthousePath(glob); !ok || path != tt.path {
+			t.Errorf("lighthousePath(%q) = %q, %v, want %q", glob, path, ok, tt.path)
+		}
+	}
+
+	for _, glob := range []string{"/blog/*.html", "/p?ge"} {
+		if _, ok := lighthousePath(glob); ok {
+			t.Errorf("lighthousePath(%q) converted a glob matching within a segment", glob)
+		}
+	}
+}
+
+func TestFromLighthouse(t *testing.T) {
+	budgets := []LighthouseBudget{
+		{
+			Path:           "/*",
+			Timings:        []LighthouseTiming{{Metric: "largest-contentful-paint", Budget: 2500}, {Metric: "first-cpu-idle", Budget: 3000}},
+			ResourceSizes:  []LighthouseResource{{ResourceType: "total", Budget: 1000}, {ResourceType: "script", Budget: 300}},
+			ResourceCounts: []LighthouseResource{{ResourceType: "third-party", Budget: 10}},
+		},
+		{
+			Path:    "/checkout",
+			Timings: []LighthouseTiming{{Metric: "cumulative-layout-shift", Budget: 0.05}},
+		},
+		{
+			Path:    "/search?",
+			Timings: []LighthouseTiming{{Metric: "interactive", Budget: 5000}},
+		},
+	}
+	performanceBudgets, cfg, dropped := FromLighthouse(budgets)
+
+	wantBudgets := []vfrogapi.PerformanceBudget{
+		{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, Warning: 2501, Error: 2501},
+	}
+	if !reflect.DeepEqual(performanceBudgets.Budgets, wantBudgets) {
+		t.Errorf("budgets = %+v, want %+v", performanceBudgets.Budgets, wantBudgets)
+	}
+	wantOverrides := []Override{{
+		Name:    "/checkout",
+		Match:   Match{Paths: []string{"/checkout**"}},
+		Budgets: []vfrogapi.PerformanceBudget{{Metric: vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, Warning: 5, Error: 5}},
+	}}
+	if !reflect.DeepEqual(cfg.Overrides, wantOverrides) {
+		t.Errorf("overrides = %+v, want %+v", cfg.Overrides, wantOverrides)
+	}
+
+	wantDropped := []string{
+		`timing "first-cpu-idle" has no VitalFrog metric`,
+		`resource size of "total", resource sizes can not be budgeted. big_payloads.total_bytes only counts the big payloads`,
+		`resource size of "script"`,
+		`resource count of "third-party"`,
+		`timing "cumulative-layout-shift" budget 0.05 becomes the threshold 0.05, which fails values at or above it`,
+		`does not budget largest_contentful_paint_ms`,
+		`entry 2 (path "/search?"): path can not be matched`,
+	}
+	if len(dropped) != len(wantDropped) {
+		t.Fatalf("dropped = %q, want %d entries", dropped, len(wantDropped))
+	}
+	for k, want := range wantDropped {
+		if !strings.Contains(dropped[k], want) {
+			t.Errorf("dropped[%d] = %q, want it to contain %q", k, dropped[k], want)
+		}
+	}
+}
+
+func TestToLighthouse(t *testing.T) {
+	below := vfrogapi.Below
+	performanceBudgets, _ := Preset("google-cwv-needs-improvement")
+	local := &Config{
+		Budgets: []vfrogapi.PerformanceBudget{
+			{Metric: vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes, Warning: 512001, Error: 512001},
+			{Metric: vfrogapi.PerformanceBudgetMetricServerResponseTimeMs, Warning: 601, Error: 601},
+			{Metric: MetricPerformanceScore, Warning: 90, Error: 50, Mode: &below},
+		},
+		Overrides: []Override{
+			{Name: "blog", Match: Match{Paths: []string{"/blog/**"}}, Budgets: []vfrogapi.PerformanceBudget{
+				{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, Warning: 3001, Error: 3001},
+				{Metric: vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, Warning: 10, Error: 10},
+			}},
+			{Name: "mobile", Match: Match{Devices: []vfrogapi.DeviceName{vfrogapi.Mobile}}},
+		},
+		Tiers: []Tier{{Name: "SLA", Effect: TierEffectError, Preset: "google-cwv-good"}},
+	}
+	lighthouseBudgets, dropped := ToLighthouse(performanceBudgets, local)
+
+	if len(lighthouseBudgets) != 2 {
+		t.Fatalf("got %d Lighthouse budgets, want 2: %+v", len(lighthouseBudgets), lighthouseBudgets)
+	}
+	all, blog := lighthouseBudgets[0], lighthouseBudgets[1]
+	if all.Path != "/" || blog.Path != "/blog/" {
+		t.Errorf("paths are %q and %q, want \"/\" and \"/blog/\"", all.Path, blog.Path)
+	}
+	wantAll := map[string]float64{
+		"largest-contentful-paint": 4000,
+		"cumulative-layout-shift":  0.25,
+		"max-potential-fid":        300,
+		"total-blocking-time":      600,
+	}
+	for _, timing := range all.Timings {
+		if want, ok := wantAll[timing.Metric]; !ok || timing.Budget != want {
+			t.Errorf("timing %s is %g, want %g", timing.Metric, timing.Budget, want)
+		}
+	}
+	if len(all.Timings) != len(wantAll) {
+		t.Errorf("got %d timings, want %d", len(all.Timings), len(wantAll))
+	}
+	if len(all.ResourceSizes) > 0 {
+		t.Errorf("resource sizes = %+v, want none", all.ResourceSizes)
+	}
+	wantBlog := map[string]float64{"largest-contentful-paint": 3000, "cumulative-layout-shift": 0.1}
+	for _, timing := range blog.Timings {
+		if want, ok := wantBlog[timing.Metric]; ok && timing.Budget != want {
+			t.Errorf("blog %s is %g, want %g", timing.Metric, timing.Budget, want)
+		}
+	}
+
+	wantDropped := []string{
+		"1 tiers",
+		"largest_contentful_paint_ms warning threshold 2500ms is dropped",
+		"big_payloads.total_bytes only counts the big payloads, Lighthouse resource sizes count every resource",
+		"server_response_time_ms has no Lighthouse timing",
+		"override blog: cumulative_layout_shift error threshold 0.1 becomes the Lighthouse budget 0.1, which passes a value of exactly 0.1",
+		`performance_score has mode "below"`,
+		"override mobile: Lighthouse budgets can not match devices or countries",
+	}
+	joined := strings.Join(dropped, "\n")
+	for _, want := range wantDropped {
+		if !strings.Contains(joined, want) {
+			t.Errorf("dropped = %q, want an entry containing %q", dropped, want)
+		}
+	}
+	if strings.Contains(joined, "budgets for all paths: cumulative_layout_shift error threshold") {
+		t.Errorf("dropped = %q, the CLS of the preset converts exactly", dropped)
+	}
+}
@@ -0,0 +1,56 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"sort"
+)
+
+// totalBlockingTime budgets the total blocking time as lab proxy for the interactivity, with Lighthouse's thresholds
+var totalBlockingTime = vital{Metric: vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs, Good: 200, Poor: 600}
+
+// presets are built-in budgets following Google's published Core Web Vitals thresholds, plus the total blocking time.
+// They are built from coreWebVitals and like Google pass values at the thresholds (see comparePreset), so they agree
+// with Assess on every value.
+var presets = func() map[string][]vfrogapi.PerformanceBudget {
+	good := make([]vfrogapi.PerformanceBudget, 0, len(coreWebVitals)+1)
+	needsImprovement := make([]vfrogapi.PerformanceBudget, 0, len(coreWebVitals)+1)
+	for _, v := range append(append([]vital{}, coreWebVitals...), totalBlockingTime) {
+		// google-cwv-good fails every metric which is not rated good
+		good = append(good, vfrogapi.PerformanceBudget{Metric: v.Metric, Warning: v.Good, Error: v.Good})
+		// google-cwv-needs-improvement warns on metrics rated needs improvement and fails poor ones
+		needsImprovement = append(needsImprovement, v.budget())
+	}
+	return map[string][]vfrogapi.PerformanceBudget{
+		"google-cwv-good":              good,
+		"google-cwv-needs-improvement": needsImprovement,
+	}
+}()
+
+// Preset returns the built-in budgets of the name. They have no id and the preset name as description.
+// Returns false for unknown names
+func Preset(name string) (*vfrogapi.PerformanceBudgets, bool) {
+	budgets, ok := presets[name]
+	if !ok {
+		return nil, false
+	}
+	return &vfrogapi.PerformanceBudgets{
+		Budgets:     append([]vfrogapi.PerformanceBudget{}, budgets...),
+		Description: name,
+	}, true
+}
+
+// PresetNames returns the names of all built-in budgets
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isPreset reports whether the budgets are a built-in preset, e.g. read back from an export
+func isPreset(budgets *vfrogapi.PerformanceBudgets) bool {
+	_, ok := presets[budgets.Description]
+	return ok && budgets.Id == 0
+}
@@ -0,0 +1,112 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"strings"
+)
+
+// Regression limits how much a metric may get worse compared to a baseline report. With both limits set, the more
+// lenient one applies ("10% or 200ms"). Set only one of them for a strict limit.
+type Regression struct {
+	Metric vfrogapi.PerformanceBudgetMetric `json:"metric"`
+	Match
+	// MaxIncrease is the allowed absolute increase in the unit of the metric (see Info). For CLS this is the score, not hundredths
+	MaxIncrease *float64 `json:"max_increase,omitempty"`
+	// MaxIncreasePercent is the allowed increase relative to the baseline value
+	MaxIncreasePercent *float64 `json:"max_increase_percent,omitempty"`
+	Severity           Severity `json:"severity"`
+}
+
+func (r Regression) validate() error {
+	if !KnownMetric(r.Metric) {
+		return fmt.Errorf("unknown metric %q", r.Metric)
+	}
+	if info, _ := Info(r.Metric); info.Computed {
+		return fmt.Errorf("regressions of the computed metric %q are not supported", r.Metric)
+	}
+	if r.MaxIncrease == nil && r.MaxIncreasePercent == nil {
+		return fmt.Errorf("regression of %q needs max_increase or max_increase_percent", r.Metric)
+	}
+	if r.Severity != SeverityWarning && r.Severity != SeverityError {
+		return fmt.Errorf("regression of %q has invalid severity %q", r.Metric, r.Severity)
+	}
+	return r.Match.validate()
+}
+
+// RegressionVerdict is the result of a Regression applied to a performance report and its baseline
+type RegressionVerdict struct {
+	Metric   vfrogapi.PerformanceBudgetMetric
+	Level    Level
+	Baseline Value
+	Current  Value
+	// Delta is Current - Baseline
+	Delta Value
+	// DeltaPercent is Delta relative to Baseline. 0 if the baseline is 0
+	DeltaPercent float64
+	// Suppressed is the suppression which downgraded the verdict from fail to warn. Nil if not suppressed
+	Suppressed *Suppression
+}
+
+// String formats baseline, current and delta. E.g. "LCP 2500ms → 2700ms (+200ms, +8.0%)"
+func (v RegressionVerdict) String() string {
+	name := string(v.Metric)
+	if info, ok := Info(v.Metric); ok {
+		name = info.Short
+	}
+	sign := "+"
+	if v.Delta.Amount < 0 {
+		sign = ""
+	}
+	return fmt.Sprintf("%s %s → %s (%s%s, %s%.1f%%)", name, v.Baseline, v.Current, sign, v.Delta, sign, v.DeltaPercent)
+}
+
+// ReportKey identifies the path, device and country combination of a performance report
+func ReportKey(report vfrogapi.PerformanceReport) string {
+	return fmt.Sprintf("%s|%s|%s", report.Path, report.Device.Name, strings.ToUpper(report.Country.Code))
+}
+
+// Baseline holds the performance reports regressions are compared against, by ReportKey
+type Baseline map[string]vfrogapi.PerformanceReport
+
+// NewBaseline indexes the performance reports. If a combination is contained several times, the first one is used
+func NewBaseline(reports []vfrogapi.PerformanceReport) Baseline {
+	baseline := Baseline{}
+	for _, report := range reports {
+		if _, ok := baseline[ReportKey(report)]; !ok {
+			baseline[ReportKey(report)] = report
+		}
+	}
+	return baseline
+}
+
+// CompareRegression compares the metric of the report against the baseline report
+func CompareRegression(r Regression, baseline, current vfrogapi.PerformanceReport) RegressionVerdict {
+	baselineValue, _ := MetricValue(baseline, r.Metric)
+	currentValue, _ := MetricValue(current, r.Metric)
+	verdict := RegressionVerdict{
+		Metric:   r.Metric,
+		Level:    LevelOk,
+		Baseline: baselineValue,
+		Current:  currentValue,
+		Delta:    Value{Amount: currentValue.Amount - baselineValue.Amount, Unit: currentValue.Unit},
+	}
+	if baselineValue.Amount != 0 {
+		verdict.DeltaPercent = verdict.Delta.Amount / baselineValue.Amount * 100
+	}
+
+	allowed := false
+	if r.MaxIncrease != nil && verdict.Delta.Amount <= *r.MaxIncrease {
+		allowed = true
+	}
+	if r.MaxIncreasePercent != nil && (verdict.Delta.Amount <= 0 || (baselineValue.Amount != 0 && verdict.DeltaPercent <= *r.MaxIncreasePercent)) {
+		allowed = true
+	}
+	if !allowed {
+		verdict.Level = LevelWarn
+		if r.Severity == SeverityError {
+			verdict.Level = LevelFail
+		}
+	}
+	return verdict
+}
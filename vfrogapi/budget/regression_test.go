@@ -0,0 +1,55 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"testing"
+)
+
+func TestCompareRegression(t *testing.T) {
+	lcp := vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs
+	cls := vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift
+	absolute, percent, none := 200.0, 10.0, 0.0
+	clsIncrease := 0.05
+	tests := []struct {
+		name     string
+		r        Regression
+		baseline vfrogapi.PerformanceReport
+		current  vfrogapi.PerformanceReport
+		want     Level
+	}{
+		{"absolute within", Regression{Metric: lcp, MaxIncrease: &absolute, Severity: SeverityError}, withVitals(2000, 0, 0), withVitals(2200, 0, 0), LevelOk},
+		{"absolute exceeded", Regression{Metric: lcp, MaxIncrease: &absolute, Severity: SeverityError}, withVitals(2000, 0, 0), withVitals(2201, 0, 0), LevelFail},
+		{"absolute exceeded as warning", Regression{Metric: lcp, MaxIncrease: &absolute, Severity: SeverityWarning}, withVitals(2000, 0, 0), withVitals(2201, 0, 0), LevelWarn},
+		{"percent within", Regression{Metric: lcp, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(3000, 0, 0), withVitals(3300, 0, 0), LevelOk},
+		{"percent exceeded", Regression{Metric: lcp, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(3000, 0, 0), withVitals(3301, 0, 0), LevelFail},
+		{"percent improvement", Regression{Metric: lcp, MaxIncreasePercent: &none, Severity: SeverityError}, withVitals(3000, 0, 0), withVitals(2000, 0, 0), LevelOk},
+		{"percent of a zero baseline", Regression{Metric: lcp, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(0, 0, 0), withVitals(1, 0, 0), LevelFail},
+		{"percent unchanged zero baseline", Regression{Metric: lcp, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(0, 0, 0), withVitals(0, 0, 0), LevelOk},
+		// 10% of 3000ms is 300ms, the more lenient limit applies
+		{"both, percent is lenient", Regression{Metric: lcp, MaxIncrease: &absolute, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(3000, 0, 0), withVitals(3300, 0, 0), LevelOk},
+		// 10% of 1000ms is 100ms
+		{"both, absolute is lenient", Regression{Metric: lcp, MaxIncrease: &absolute, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(1000, 0, 0), withVitals(1200, 0, 0), LevelOk},
+		{"both exceeded", Regression{Metric: lcp, MaxIncrease: &absolute, MaxIncreasePercent: &percent, Severity: SeverityError}, withVitals(1000, 0, 0), withVitals(1201, 0, 0), LevelFail},
+		{"CLS absolute in score", Regression{Metric: cls, MaxIncrease: &clsIncrease, Severity: SeverityError}, withVitals(0, 0.1, 0), withVitals(0, 0.15, 0), LevelOk},
+		{"CLS absolute exceeded", Regression{Metric: cls, MaxIncrease: &clsIncrease, Severity: SeverityError}, withVitals(0, 0.1, 0), withVitals(0, 0.16, 0), LevelFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareRegression(tt.r, tt.baseline, tt.current); got.Level != tt.want {
+				t.Errorf("CompareRegression() = %s (%s), want %s", got.Level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegressionVerdictString(t *testing.T) {
+	percent := 5.0
+	r := Regression{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, MaxIncreasePercent: &percent, Severity: SeverityError}
+	verdict := CompareRegression(r, withVitals(2500, 0, 0), withVitals(2700, 0, 0))
+	if verdict.DeltaPercent != 8 {
+		t.Errorf("DeltaPercent = %v, want 8", verdict.DeltaPercent)
+	}
+	if got, want := verdict.String(), "LCP 2500ms → 2700ms (+200ms, +8.0%)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,500 @@
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/internal/pathglob"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi/score"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is a local budgets file. Budgets use the schema of vfrogapi.PerformanceBudget
+type Config struct {
+	// Budgets are layered over the server side budgets. A budget replaces the server budget of the same metric
+	Budgets []vfrogapi.PerformanceBudget `json:"budgets,omitempty"`
+	// Overrides are layered over Budgets in order, for the performance reports they match
+	Overrides []Override `json:"overrides,omitempty"`
+	// Rules are named expressions judging a performance report as a whole
+	Rules []ExprRule `json:"rules,omitempty"`
+	// Regressions limit how much metrics may get worse compared to a baseline report. Skipped without baseline
+	Regressions []Regression `json:"regressions,omitempty"`
+	// Tiers are named budget sets judged side by side with the layered budgets
+	Tiers []Tier `json:"tiers,omitempty"`
+}
+
+// Match selects performance reports by path, device and country. Empty fields match everything
+type Match struct {
+	// Globs matching the path. '*' matches within a path segment, '**' across segments
+	Paths     []string              `json:"paths,omitempty"`
+	Devices   []vfrogapi.DeviceName `json:"devices,omitempty"`
+	Countries []string              `json:"countries,omitempty"`
+}
+
+// Matches reports whether the performance report is selected
+func (m Match) Matches(report vfrogapi.PerformanceReport) bool {
+	if len(m.Paths) > 0 && !pathglob.MatchAny(m.Paths, report.Path) {
+		return false
+	}
+	if len(m.Devices) > 0 {
+		found := false
+		for _, d := range m.Devices {
+			found = found || d == report.Device.Name
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(m.Countries) > 0 {
+		found := false
+		for _, c := range m.Countries {
+			found = found || strings.EqualFold(c, report.Country.Code)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Match) validate() error {
+	for _, pattern := range m.Paths {
+		if !pathglob.Valid(pattern) {
+			return fmt.Errorf("invalid path glob %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Override replaces budgets for matching performance reports
+type Override struct {
+	Name string `json:"name"`
+	Match
+	Budgets []vfrogapi.PerformanceBudget `json:"budgets"`
+}
+
+// Severity of an ExprRule
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// ExprRule is a named expression (see Expr) applied to matching performance reports. If the expression is true,
+// the rule is violated with its severity. E.g. "lcp > 2500 && largest_contentful_paint.lazy_loaded"
+type ExprRule struct {
+	Name string `json:"name"`
+	Match
+	Severity Severity `json:"severity"`
+	Expr     string   `json:"expr"`
+
+	compiled *Expr
+}
+
+// Compile parses the expression of the rule. Called by ReadConfig
+func (r *ExprRule) Compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule has no name")
+	}
+	if r.Severity != SeverityWarning && r.Severity != SeverityError {
+		return fmt.Errorf("rule %q has invalid severity %q. Must be %q or %q", r.Name, r.Severity, SeverityWarning, SeverityError)
+	}
+	if err := r.Match.validate(); err != nil {
+		return fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	compiled, err := ParseExpr(r.Expr)
+	if err != nil {
+		return fmt.Errorf("rule %q has invalid expr %q: %w", r.Name, r.Expr, err)
+	}
+	r.compiled = compiled
+	return nil
+}
+
+// RuleVerdict is the result of an ExprRule applied to a single performance report
+type RuleVerdict struct {
+	Name  string
+	Level Level
+	Expr  string
+	// Values of all identifiers used in the expression
+	Values map[string]string
+}
+
+// String formats the rule with the values involved. E.g. "lazy-lcp (lcp=4100, largest_contentful_paint.lazy_loaded=true)"
+func (v RuleVerdict) String() string {
+	names := make([]string, 0, len(v.Values))
+	for name := range v.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, fmt.Sprintf("%s=%s", name, v.Values[name]))
+	}
+	return fmt.Sprintf("%s (%s)", v.Name, strings.Join(values, ", "))
+}
+
+// ReadConfig reads a local budgets file
+func ReadConfig(fileName string) (*Config, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+	cfg := &Config{}
+	err = json.Unmarshal(raw, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not json unmarshal %q: %w", fileName, err)
+	}
+	err = cfg.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("invalid budgets file %q: %w", fileName, err)
+	}
+	return cfg, nil
+}
+
+// Compile checks the overrides and compiles all rule expressions. Must be called before a Config read without
+// ReadConfig is evaluated
+func (c *Config) Compile() error {
+	for k, o := range c.Overrides {
+		if o.Name == "" {
+			return fmt.Errorf("override %d has no name", k)
+		}
+		if err := o.Match.validate(); err != nil {
+			return fmt.Errorf("override %q: %w", o.Name, err)
+		}
+	}
+	for k := range c.Rules {
+		if err := c.Rules[k].Compile(); err != nil {
+			return fmt.Errorf("could not compile rule %d: %w", k, err)
+		}
+	}
+	for k, r := range c.Regressions {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("invalid regression %d: %w", k, err)
+		}
+	}
+	for k, t := range c.Tiers {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("invalid tier %d: %w", k, err)
+		}
+		if t.Preset != "" && !t.Resolved() {
+			preset, ok := Preset(t.Preset)
+			if !ok {
+				return fmt.Errorf("tier %q has unknown preset %q. Use one of %v", t.Name, t.Preset, PresetNames())
+			}
+			c.Tiers[k].Budgets = preset.Budgets
+		}
+	}
+	return nil
+}
+
+// Rule is a budget together with the rule it came from
+type Rule struct {
+	Budget vfrogapi.PerformanceBudget
+	// Source names where the budget is defined. E.g. "server budgets 7", "preset google-cwv-good", "local budgets" or
+	// "override checkout"
+	Source string
+	// Exclusive budgets pass values at their thresholds. True for the presets, see comparePreset
+	Exclusive bool
+}
+
+const (
+	serverSourcePrefix = "server budgets"
+	presetSourcePrefix = "preset"
+)
+
+// FromServer reports whether the rule source names the base budgets: the server side budgets or a preset replacing them
+func FromServer(source string) bool {
+	return strings.HasPrefix(source, serverSourcePrefix) || strings.HasPrefix(source, presetSourcePrefix)
+}
+
+// Rules layers the server side budgets, the local budgets and the local overrides
+type Rules struct {
+	server       *vfrogapi.PerformanceBudgets
+	local        *Config
+	baseline     Baseline
+	suppressions []Suppression
+	now          time.Time
+	scoring      *score.Weighting
+}
+
+// NewRules creates the rules of the server side budgets (or a Preset) and an optional local budgets file. Both may be nil
+func NewRules(server *vfrogapi.PerformanceBudgets, local *Config) Rules {
+	return Rules{server: server, local: local}
+}
+
+// WithBaseline returns the rules comparing regressions against the baseline
+func (r Rules) WithBaseline(baseline Baseline) Rules {
+	r.baseline = baseline
+	return r
+}
+
+// Baseline returns the baseline regressions are compared against. Nil if there is none
+func (r Rules) Baseline() Baseline {
+	return r.baseline
+}
+
+// WithSuppressions returns the rules downgrading failed verdicts matched by a suppression not expired at now to warnings
+func (r Rules) WithSuppressions(suppressions []Suppression, now time.Time) Rules {
+	r.suppressions = suppressions
+	r.now = now
+	return r
+}
+
+// Suppressions returns all suppressions, including the expired ones
+func (r Rules) Suppressions() []Suppression {
+	return r.suppressions
+}
+
+// ExpiredSuppressions returns the suppressions which are expired. They are not applied anymore
+func (r Rules) ExpiredSuppressions() []Suppression {
+	expired := make([]Suppression, 0)
+	for _, s := range r.suppressions {
+		if s.Expired(r.now) {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
+
+// suppression returns the first active suppression of the metric matching the performance report
+func (r Rules) suppression(metric vfrogapi.PerformanceBudgetMetric, report vfrogapi.PerformanceReport) *Suppression {
+	for k, s := range r.suppressions {
+		if s.Metric == metric && s.Matches(report) && !s.Expired(r.now) {
+			return &r.suppressions[k]
+		}
+	}
+	return nil
+}
+
+// WithScoring returns the rules computing the performance score with the weighting
+func (r Rules) WithScoring(w score.Weighting) Rules {
+	r.scoring = &w
+	return r
+}
+
+// Scoring returns the weighting the performance score is computed with
+func (r Rules) Scoring() score.Weighting {
+	if r.scoring == nil {
+		return score.Default()
+	}
+	return *r.scoring
+}
+
+// MetricValue is MetricValue with the performance score computed by the weighting of the rules
+func (r Rules) MetricValue(report vfrogapi.PerformanceReport, metric vfrogapi.PerformanceBudgetMetric) (Value, bool) {
+	if metric == MetricPerformanceScore {
+		return Value{Amount: r.Scoring().Score(report), Unit: UnitScore}, true
+	}
+	return MetricValue(report, metric)
+}
+
+// Server returns the server side budgets. Might be nil
+func (r Rules) Server() *vfrogapi.PerformanceBudgets {
+	return r.server
+}
+
+// Local returns the local budgets file. Might be nil
+func (r Rules) Local() *Config {
+	return r.local
+}
+
+// Empty reports whether there is not a single budget defined
+func (r Rules) Empty() bool {
+	return len(r.Metrics()) == 0
+}
+
+// Metrics returns every metric any rule defines a budget for, ordered like Metrics
+func (r Rules) Metrics() []vfrogapi.PerformanceBudgetMetric {
+	defined := map[vfrogapi.PerformanceBudgetMetric]struct{}{}
+	add := func(budgets []vfrogapi.PerformanceBudget) {
+		for _, b := range budgets {
+			defined[b.Metric] = struct{}{}
+		}
+	}
+	if r.server != nil {
+		add(r.server.Budgets)
+	}
+	if r.local != nil {
+		add(r.local.Budgets)
+		for _, o := range r.local.Overrides {
+			add(o.Budgets)
+		}
+		for _, t := range r.local.Tiers {
+			add(t.Budgets)
+		}
+	}
+
+	metrics := make([]vfrogapi.PerformanceBudgetMetric, 0, len(defined))
+	for _, m := range Metrics {
+		if _, ok := defined[m]; ok {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// serverSource names the server side budgets as rule source
+func (r Rules) serverSource() string {
+	if isPreset(r.server) {
+		return fmt.Sprintf("%s %s", presetSourcePrefix, r.server.Description)
+	}
+	return fmt.Sprintf("%s %d", serverSourcePrefix, r.server.Id)
+}
+
+// For returns the budgets which apply to the performance report. Per metric the budgets of the last matching layer win.
+func (r Rules) For(report vfrogapi.PerformanceReport) []Rule {
+	rules := make([]Rule, 0)
+	layer := func(budgets []vfrogapi.PerformanceBudget, source string, exclusive bool) {
+		replaced := map[vfrogapi.PerformanceBudgetMetric]struct{}{}
+		for _, b := range budgets {
+			replaced[b.Metric] = struct{}{}
+		}
+		kept := make([]Rule, 0, len(rules)+len(budgets))
+		for _, rule := range rules {
+			if _, ok := replaced[rule.Budget.Metric]; !ok {
+				kept = append(kept, rule)
+			}
+		}
+		for _, b := range budgets {
+			kept = append(kept, Rule{Budget: b, Source: source, Exclusive: exclusive})
+		}
+		rules = kept
+	}
+
+	if r.server != nil {
+		layer(r.server.Budgets, r.serverSource(), isPreset(r.server))
+	}
+	if r.local != nil {
+		layer(r.local.Budgets, "local budgets", false)
+		for _, o := range r.local.Overrides {
+			if o.Matches(report) {
+				layer(o.Budgets, fmt.Sprintf("override %s", o.Name), false)
+			}
+		}
+	}
+	return rules
+}
+
+// Evaluate applies all rules matching the performance report and judges it against every tier. Budgets of unknown
+// metrics are skipped. Failed budgets and regressions of suppressed metrics are downgraded to warnings.
+func (r Rules) Evaluate(report vfrogapi.PerformanceReport) Result {
+	result := Result{Report: report, Verdicts: make([]Verdict, 0)}
+	for _, rule := range r.For(report) {
+		value, ok := r.MetricValue(report, rule.Budget.Metric)
+		if !ok {
+			continue
+		}
+		verdict := compare(value, rule.Budget, rule.Exclusive)
+		verdict.Rule = rule.Source
+		if verdict.Level == LevelFail {
+			if verdict.Suppressed = r.suppression(verdict.Metric, report); verdict.Suppressed != nil {
+				verdict.Level = LevelWarn
+			}
+		}
+		result.Verdicts = append(result.Verdicts, verdict)
+	}
+
+	if r.local != nil {
+		for _, rule := range r.local.Rules {
+			if rule.compiled == nil || !rule.Matches(report) {
+				continue
+			}
+			verdict := RuleVerdict{Name: rule.Name, Level: LevelOk, Expr: rule.Expr, Values: rule.compiled.Values(report)}
+			if rule.compiled.Eval(report) {
+				verdict.Level = LevelWarn
+				if rule.Severity == SeverityError {
+					verdict.Level = LevelFail
+				}
+			}
+			result.RuleVerdicts = append(result.RuleVerdicts, verdict)
+		}
+
+		if baseline, ok := r.baseline[ReportKey(report)]; ok {
+			for _, regression := range r.local.Regressions {
+				if !regression.Matches(report) {
+					continue
+				}
+				verdict := CompareRegression(regression, baseline, report)
+				if verdict.Level == LevelFail {
+					if verdict.Suppressed = r.suppression(verdict.Metric, report); verdict.Suppressed != nil {
+						verdict.Level = LevelWarn
+					}
+				}
+				result.RegressionVerdicts = append(result.RegressionVerdicts, verdict)
+			}
+		}
+
+		for _, tier := range r.local.Tiers {
+			result.TierVerdicts = append(result.TierVerdicts, r.evaluateTier(tier, report))
+		}
+	}
+	return result
+}
+
+// evaluateTier applies the budgets of the tier. Like the layered budgets, failures of suppressed metrics are downgraded
+// to warnings and the budgets of a preset pass values at their thresholds
+func (r Rules) evaluateTier(tier Tier, report vfrogapi.PerformanceReport) TierVerdict {
+	tierVerdict := TierVerdict{Tier: tier.Name, Effect: tier.Effect, Level: LevelOk}
+	for _, b := range tier.Budgets {
+		value, ok := r.MetricValue(report, b.Metric)
+		if !ok {
+			continue
+		}
+		verdict := compare(value, b, tier.Preset != "")
+		verdict.Rule = fmt.Sprintf("tier %s", tier.Name)
+		if verdict.Level == LevelFail {
+			if verdict.Suppressed = r.suppression(verdict.Metric, report); verdict.Suppressed != nil {
+				verdict.Level = LevelWarn
+			}
+		}
+		if verdict.Level > tierVerdict.Level {
+			tierVerdict.Level = verdict.Level
+		}
+		tierVerdict.Verdicts = append(tierVerdict.Verdicts, verdict)
+	}
+	return tierVerdict
+}
+
+// Problem is an inconsistency of a budget together with the source of the budget
+type Problem struct {
+	vfrogapi.BudgetProblem
+	// Source names where the budget is defined, like Rule.Source
+	Source string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Source, p.BudgetProblem)
+}
+
+// Validate checks the server side budgets, the local budgets and every override for internal consistency, see
+// vfrogapi.PerformanceBudgets.Validate. Computed metrics like the performance score are only known locally, so they
+// are not an unknown metric there.
+func (r Rules) Validate() []Problem {
+	problems := make([]Problem, 0)
+	check := func(budgets []vfrogapi.PerformanceBudget, source string, computedKnown bool) {
+		for _, p := range (vfrogapi.PerformanceBudgets{Budgets: budgets}).Validate() {
+			if info, ok := Info(p.Metric); ok && info.Computed && computedKnown && p.Kind == vfrogapi.BudgetProblemUnknownMetric {
+				continue
+			}
+			problems = append(problems, Problem{BudgetProblem: p, Source: source})
+		}
+	}
+
+	if r.server != nil {
+		check(r.server.Budgets, r.serverSource(), false)
+	}
+	if r.local != nil {
+		check(r.local.Budgets, "local budgets", true)
+		for _, o := range r.local.Overrides {
+			check(o.Budgets, fmt.Sprintf("override %s", o.Name), true)
+		}
+		for _, t := range r.local.Tiers {
+			check(t.Budgets, fmt.Sprintf("tier %s", t.Name), true)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,134 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"math"
+	"sort"
+)
+
+// SuggestOptions configures Suggest
+type SuggestOptions struct {
+	// WarningPercentile and ErrorPercentile (0–100) of the historical values the thresholds are based on
+	WarningPercentile float64
+	ErrorPercentile   float64
+	// Headroom in percent added on top of the percentiles, so regular noise does not trigger the budgets
+	Headroom float64
+	// Metrics to suggest budgets for. All metrics apart from computed ones if empty
+	Metrics []vfrogapi.PerformanceBudgetMetric
+}
+
+func (o SuggestOptions) validate() error {
+	if o.WarningPercentile <= 0 || o.WarningPercentile > 100 || o.ErrorPercentile <= 0 || o.ErrorPercentile > 100 {
+		return fmt.Errorf("percentiles must be between 0 and 100")
+	}
+	if o.WarningPercentile > o.ErrorPercentile {
+		return fmt.Errorf("warning percentile %g must not be above the error percentile %g", o.WarningPercentile, o.ErrorPercentile)
+	}
+	if o.Headroom < 0 {
+		return fmt.Errorf("headroom must not be negative")
+	}
+	for _, metric := range o.Metrics {
+		info, ok := Info(metric)
+		if !ok {
+			return fmt.Errorf("unknown metric %q", metric)
+		}
+		if info.Computed {
+			return fmt.Errorf("can not suggest budgets for the computed metric %q", metric)
+		}
+	}
+	return nil
+}
+
+// Distribution summarizes the historical values of a metric
+type Distribution struct {
+	Metric vfrogapi.PerformanceBudgetMetric
+	Count  int
+	Min    Value
+	Median Value
+	P75    Value
+	P95    Value
+	Max    Value
+}
+
+// Suggestion is a suggested budget together with the distribution it is based on
+type Suggestion struct {
+	Budget       vfrogapi.PerformanceBudget
+	Distribution Distribution
+}
+
+// Suggest proposes a budget per metric from historical performance reports. The thresholds are the chosen percentiles
+// plus headroom, rounded up to a readable step (10ms, 1kB or 0.01 CLS). The error threshold is always above the warning
+// threshold.
+func Suggest(reports []vfrogapi.PerformanceReport, opts SuggestOptions) ([]Suggestion, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("need at least a single performance report")
+	}
+
+	metrics := opts.Metrics
+	if len(metrics) == 0 {
+		for _, info := range metricInfos {
+			if !info.Computed {
+				metrics = append(metrics, info.Metric)
+			}
+		}
+	}
+
+	suggestions := make([]Suggestion, 0, len(metrics))
+	for _, metric := range metrics {
+		info, _ := Info(metric)
+		amounts := make([]float64, 0, len(reports))
+		for _, report := range reports {
+			value, _ := MetricValue(report, metric)
+			amounts = append(amounts, value.Amount)
+		}
+		sort.Float64s(amounts)
+
+		value := func(amount float64) Value {
+			return Value{Amount: amount, Unit: info.Unit}
+		}
+		distribution := Distribution{
+			Metric: metric,
+			Count:  len(amounts),
+			Min:    value(amounts[0]),
+			Median: value(percentile(amounts, 0.5)),
+			P75:    value(percentile(amounts, 0.75)),
+			P95:    value(percentile(amounts, 0.95)),
+			Max:    value(amounts[len(amounts)-1]),
+		}
+
+		warning := suggestThreshold(info, percentile(amounts, opts.WarningPercentile/100), opts.Headroom)
+		errorThreshold := suggestThreshold(info, percentile(amounts, opts.ErrorPercentile/100), opts.Headroom)
+		if errorThreshold <= warning {
+			errorThreshold = warning + thresholdStep(info)
+		}
+		mode := vfrogapi.Above
+		suggestions = append(suggestions, Suggestion{
+			Budget:       vfrogapi.PerformanceBudget{Metric: metric, Warning: warning, Error: errorThreshold, Mode: &mode},
+			Distribution: distribution,
+		})
+	}
+	return suggestions, nil
+}
+
+// thresholdStep is the step thresholds are rounded up to, in budget units
+func thresholdStep(info MetricInfo) int32 {
+	switch info.Unit {
+	case UnitMilliseconds:
+		return 10
+	case UnitBytes:
+		return 1000
+	}
+	return 1
+}
+
+func suggestThreshold(info MetricInfo, amount, headroom float64) int32 {
+	step := float64(thresholdStep(info))
+	// The epsilon keeps float noise (0.1*100 = 10.000000000000002) from rounding up a whole step
+	threshold := math.Ceil(amount*(1+headroom/100)*info.BudgetScale/step-1e-9) * step
+	// A zero threshold would flag every value
+	return int32(math.Max(threshold, step))
+}
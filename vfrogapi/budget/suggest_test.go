@@ -0,0 +1,97 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	lcp := vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs
+	cls := vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift
+	bytes := vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes
+	lcps := func(values ...int32) []vfrogapi.PerformanceReport {
+		reports := make([]vfrogapi.PerformanceReport, 0, len(values))
+		for _, v := range values {
+			reports = append(reports, withVitals(v, 0, 0))
+		}
+		return reports
+	}
+	tests := []struct {
+		name           string
+		reports        []vfrogapi.PerformanceReport
+		opts           SuggestOptions
+		warning, error int32
+	}{
+		{"percentiles", lcps(5000, 1000, 3000, 2000, 4000), SuggestOptions{WarningPercentile: 50, ErrorPercentile: 90, Metrics: []vfrogapi.PerformanceBudgetMetric{lcp}}, 3000, 4600},
+		{"headroom", lcps(5000, 1000, 3000, 2000, 4000), SuggestOptions{WarningPercentile: 50, ErrorPercentile: 90, Headroom: 10, Metrics: []vfrogapi.PerformanceBudgetMetric{lcp}}, 3300, 5060},
+		{"rounded up to 10ms", lcps(2001, 2001), SuggestOptions{WarningPercentile: 50, ErrorPercentile: 50, Metrics: []vfrogapi.PerformanceBudgetMetric{lcp}}, 2010, 2020},
+		{"error above warning", lcps(2000, 2000), SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95, Metrics: []vfrogapi.PerformanceBudgetMetric{lcp}}, 2000, 2010},
+		{"zero values", lcps(0, 0), SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95, Metrics: []vfrogapi.PerformanceBudgetMetric{lcp}}, 10, 20},
+		{"CLS in hundredths", []vfrogapi.PerformanceReport{withVitals(0, 0.05, 0), withVitals(0, 0.1, 0), withVitals(0, 0.12, 0)}, SuggestOptions{WarningPercentile: 50, ErrorPercentile: 100, Metrics: []vfrogapi.PerformanceBudgetMetric{cls}}, 10, 12},
+		{"bytes rounded up to 1kB", []vfrogapi.PerformanceReport{{BigPayloads: vfrogapi.BigPayloads{TotalBytes: 1500}}}, SuggestOptions{WarningPercentile: 50, ErrorPercentile: 100, Metrics: []vfrogapi.PerformanceBudgetMetric{bytes}}, 2000, 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := Suggest(tt.reports, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(suggestions) != 1 {
+				t.Fatalf("got %d suggestions, want 1", len(suggestions))
+			}
+			b := suggestions[0].Budget
+			if b.Warning != tt.warning || b.Error != tt.error {
+				t.Errorf("Suggest() = %d/%d, want %d/%d", b.Warning, b.Error, tt.warning, tt.error)
+			}
+			if b.Mode == nil || *b.Mode != vfrogapi.Above {
+				t.Errorf("Suggest() mode = %v, want above", b.Mode)
+			}
+		})
+	}
+}
+
+func TestSuggestDistribution(t *testing.T) {
+	reports := []vfrogapi.PerformanceReport{withVitals(5000, 0, 0), withVitals(1000, 0, 0), withVitals(3000, 0, 0)}
+	suggestions, err := Suggest(reports, SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range suggestions {
+		if info, _ := Info(s.Budget.Metric); info.Computed {
+			t.Errorf("suggested a budget for the computed metric %s", s.Budget.Metric)
+		}
+		if s.Budget.Metric != vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs {
+			continue
+		}
+		d := s.Distribution
+		if d.Count != 3 || d.Min.Amount != 1000 || d.Median.Amount != 3000 || d.P75.Amount != 4000 || d.P95.Amount != 4800 || d.Max.Amount != 5000 {
+			t.Errorf("distribution = %+v", d)
+		}
+	}
+}
+
+func TestSuggestErrors(t *testing.T) {
+	reports := []vfrogapi.PerformanceReport{withVitals(1000, 0, 0)}
+	tests := []struct {
+		name    string
+		reports []vfrogapi.PerformanceReport
+		opts    SuggestOptions
+		want    string
+	}{
+		{"zero percentile", reports, SuggestOptions{WarningPercentile: 0, ErrorPercentile: 95}, "between 0 and 100"},
+		{"percentile above 100", reports, SuggestOptions{WarningPercentile: 75, ErrorPercentile: 101}, "between 0 and 100"},
+		{"warning above error", reports, SuggestOptions{WarningPercentile: 95, ErrorPercentile: 75}, "must not be above the error percentile"},
+		{"negative headroom", reports, SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95, Headroom: -1}, "headroom must not be negative"},
+		{"unknown metric", reports, SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95, Metrics: []vfrogapi.PerformanceBudgetMetric{"foo"}}, `unknown metric "foo"`},
+		{"computed metric", reports, SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95, Metrics: []vfrogapi.PerformanceBudgetMetric{MetricPerformanceScore}}, "computed metric"},
+		{"no reports", nil, SuggestOptions{WarningPercentile: 75, ErrorPercentile: 95}, "at least a single performance report"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Suggest(tt.reports, tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Suggest() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package budget
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"os"
+	"time"
+)
+
+// suppressionDateLayout is the layout of Suppression.Expires
+const suppressionDateLayout = "2006-01-02"
+
+// Suppression downgrades failed budgets of a metric to warnings for matching performance reports, until it expires.
+// Used to knowingly ship a violation for a while, e.g. a marketing video on a single path.
+type Suppression struct {
+	Metric vfrogapi.PerformanceBudgetMetric `json:"metric"`
+	Match
+	Reason string `json:"reason"`
+	Owner  string `json:"owner"`
+	// Expires is the last day (YYYY-MM-DD, UTC) the suppression applies
+	Expires string `json:"expires"`
+}
+
+// SuppressionsFile is the format of a suppressions file
+type SuppressionsFile struct {
+	Suppressions []Suppression `json:"suppressions"`
+}
+
+func (s Suppression) validate() error {
+	if !KnownMetric(s.Metric) {
+		return fmt.Errorf("unknown metric %q", s.Metric)
+	}
+	if s.Reason == "" || s.Owner == "" {
+		return fmt.Errorf("suppression of %q needs a reason and an owner", s.Metric)
+	}
+	if _, err := time.Parse(suppressionDateLayout, s.Expires); err != nil {
+		return fmt.Errorf("suppression of %q has invalid expires %q. Must be YYYY-MM-DD", s.Metric, s.Expires)
+	}
+	return s.Match.validate()
+}
+
+// Expired reports whether the last day of the suppression is over
+func (s Suppression) Expired(now time.Time) bool {
+	expires, err := time.Parse(suppressionDateLayout, s.Expires)
+	if err != nil {
+		return true
+	}
+	return !now.UTC().Before(expires.AddDate(0, 0, 1))
+}
+
+// String formats the suppression. E.g. "LCP until 2026-12-31 by jane: marketing video"
+func (s Suppression) String() string {
+	name := string(s.Metric)
+	if info, ok := Info(s.Metric); ok {
+		name = info.Short
+	}
+	return fmt.Sprintf("%s until %s by %s: %s", name, s.Expires, s.Owner, s.Reason)
+}
+
+// ReadSuppressions reads a suppressions file
+func ReadSuppressions(fileName string) ([]Suppression, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %q: %w", fileName, err)
+	}
+	file := SuppressionsFile{}
+	err = json.Unmarshal(raw, &file)
+	if err != nil {
+		return nil, fmt.Errorf("could not json unmarshal %q: %w", fileName, err)
+	}
+	for k, s := range file.Suppressions {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("invalid suppression %d in %q: %w", k, fileName, err)
+		}
+	}
+	return file.Suppressions, nil
+}
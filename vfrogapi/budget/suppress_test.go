@@ -0,0 +1,70 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"testing"
+	"time"
+)
+
+func TestSuppressionExpired(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	tests := []struct {
+		name    string
+		expires string
+		now     time.Time
+		want    bool
+	}{
+		{"day before", "2026-06-30", time.Date(2026, 6, 29, 12, 0, 0, 0, time.UTC), false},
+		{"start of the last day", "2026-06-30", time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC), false},
+		{"end of the last day", "2026-06-30", time.Date(2026, 6, 30, 23, 59, 59, 0, time.UTC), false},
+		{"day after", "2026-06-30", time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), true},
+		// 01:00 in Berlin is still the last day in UTC
+		{"other time zone before UTC midnight", "2026-06-30", time.Date(2026, 7, 1, 1, 0, 0, 0, berlin), false},
+		{"other time zone after UTC midnight", "2026-06-30", time.Date(2026, 7, 1, 2, 0, 0, 0, berlin), true},
+		{"invalid date", "30.06.2026", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"empty date", "", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Suppression{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, Expires: tt.expires}
+			if got := s.Expired(tt.now); got != tt.want {
+				t.Errorf("Expired(%s) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuppressionsDowngradeFailures(t *testing.T) {
+	lcp := vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs
+	server := &vfrogapi.PerformanceBudgets{Budgets: []vfrogapi.PerformanceBudget{{Metric: lcp, Warning: 2501, Error: 4001}}}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	active := Suppression{Metric: lcp, Match: Match{Paths: []string{"/video"}}, Reason: "video", Owner: "jane", Expires: "2026-06-30"}
+	expired := Suppression{Metric: lcp, Reason: "old", Owner: "joe", Expires: "2026-05-31"}
+	rules := NewRules(server, nil).WithSuppressions([]Suppression{active, expired}, now)
+
+	tests := []struct {
+		name       string
+		path       string
+		lcp        int32
+		want       Level
+		suppressed bool
+	}{
+		{"failure on a suppressed path", "/video", 5000, LevelWarn, true},
+		{"warning is not suppressed", "/video", 3000, LevelWarn, false},
+		{"failure on another path", "/", 5000, LevelFail, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := withVitals(tt.lcp, 0, 0)
+			report.Path = tt.path
+			verdict, _ := rules.Evaluate(report).Verdict(lcp)
+			if verdict.Level != tt.want || (verdict.Suppressed != nil) != tt.suppressed {
+				t.Errorf("verdict = %s, suppressed %v, want %s, suppressed %v", verdict.Level, verdict.Suppressed != nil, tt.want, tt.suppressed)
+			}
+		})
+	}
+
+	if got := rules.ExpiredSuppressions(); len(got) != 1 || got[0].Owner != "joe" {
+		t.Errorf("ExpiredSuppressions() = %+v, want the suppression of joe", got)
+	}
+}
@@ -0,0 +1,111 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"strings"
+)
+
+// TierEffect is how a tier affects the overall verdict and exit code
+type TierEffect string
+
+const (
+	// TierEffectError keeps the levels of the tier, so failing it fails the run
+	TierEffectError TierEffect = "error"
+	// TierEffectWarning downgrades failures of the tier to warnings
+	TierEffectWarning TierEffect = "warning"
+	// TierEffectNone only reports the tier
+	TierEffectNone TierEffect = "none"
+)
+
+// Tier is a named budget set judged side by side with the layered budgets, e.g. "contractual SLA", "team target" or
+// "aspirational". Its budgets are either listed, a Preset or the server side budgets of an id, which the caller
+// resolves into Budgets.
+type Tier struct {
+	Name   string     `json:"name"`
+	Effect TierEffect `json:"effect"`
+	// PerformanceBudgetsId of server side budgets, fetched into Budgets
+	PerformanceBudgetsId *int32 `json:"performance_budgets_id,omitempty"`
+	// Preset name, resolved into Budgets by Config.Compile
+	Preset  string                       `json:"preset,omitempty"`
+	Budgets []vfrogapi.PerformanceBudget `json:"budgets,omitempty"`
+}
+
+// Resolved reports whether the budgets of the tier are known
+func (t Tier) Resolved() bool {
+	return len(t.Budgets) > 0
+}
+
+func (t Tier) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("tier has no name")
+	}
+	switch t.Effect {
+	case TierEffectError, TierEffectWarning, TierEffectNone:
+	default:
+		return fmt.Errorf("tier %q has unknown effect %q. Use %q, %q or %q", t.Name, t.Effect, TierEffectError, TierEffectWarning, TierEffectNone)
+	}
+	if t.Preset != "" && t.PerformanceBudgetsId != nil {
+		return fmt.Errorf("tier %q has a preset and a performance budgets id. Use one of them", t.Name)
+	}
+	if t.Preset == "" && t.PerformanceBudgetsId == nil && len(t.Budgets) == 0 {
+		return fmt.Errorf("tier %q has no budgets, preset or performance budgets id", t.Name)
+	}
+	return nil
+}
+
+// level returns the level the tier contributes to the overall verdict
+func (e TierEffect) level(level Level) Level {
+	switch e {
+	case TierEffectNone:
+		return LevelOk
+	case TierEffectWarning:
+		if level == LevelFail {
+			return LevelWarn
+		}
+	}
+	return level
+}
+
+// TierVerdict is the result of a tier for a performance report
+type TierVerdict struct {
+	Tier   string
+	Effect TierEffect
+	// Level is the highest level of the verdicts, regardless of the effect
+	Level    Level
+	Verdicts []Verdict
+}
+
+// EffectiveLevel is the level the tier contributes to the overall verdict, depending on its effect
+func (v TierVerdict) EffectiveLevel() Level {
+	return v.Effect.level(v.Level)
+}
+
+// String is a compact verdict per metric, e.g. "team target ✖ LCP, CLS ! TBT" or "contractual SLA ✔"
+func (v TierVerdict) String() string {
+	fails, warnings := make([]string, 0), make([]string, 0)
+	for _, verdict := range v.Verdicts {
+		short := string(verdict.Metric)
+		if info, ok := Info(verdict.Metric); ok {
+			short = info.Short
+		}
+		switch verdict.Level {
+		case LevelFail:
+			fails = append(fails, short)
+		case LevelWarn:
+			warnings = append(warnings, short)
+		}
+	}
+
+	parts := []string{v.Tier}
+	if len(fails) > 0 {
+		parts = append(parts, fmt.Sprintf("✖ %s", strings.Join(fails, ", ")))
+	}
+	if len(warnings) > 0 {
+		parts = append(parts, fmt.Sprintf("! %s", strings.Join(warnings, ", ")))
+	}
+	if len(parts) == 1 {
+		parts = append(parts, "✔")
+	}
+	return strings.Join(parts, " ")
+}
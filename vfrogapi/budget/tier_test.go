@@ -0,0 +1,89 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"strings"
+	"testing"
+)
+
+func TestTierEvaluation(t *testing.T) {
+	lcp := vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs
+	cls := vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift
+	budgets := []vfrogapi.PerformanceBudget{{Metric: lcp, Warning: 2501, Error: 4001}, {Metric: cls, Warning: 11, Error: 26}}
+	tests := []struct {
+		name      string
+		effect    TierEffect
+		report    vfrogapi.PerformanceReport
+		level     Level
+		effective Level
+		str       string
+	}{
+		{"error passes", TierEffectError, withVitals(2000, 0.05, 0), LevelOk, LevelOk, "SLA ✔"},
+		{"error warns", TierEffectError, withVitals(3000, 0.05, 0), LevelWarn, LevelWarn, "SLA ! LCP"},
+		{"error fails", TierEffectError, withVitals(5000, 0.2, 0), LevelFail, LevelFail, "SLA ✖ LCP ! CLS"},
+		{"warning downgrades failures", TierEffectWarning, withVitals(5000, 0.3, 0), LevelFail, LevelWarn, "SLA ✖ LCP, CLS"},
+		{"warning keeps warnings", TierEffectWarning, withVitals(3000, 0.05, 0), LevelWarn, LevelWarn, "SLA ! LCP"},
+		{"none only reports", TierEffectNone, withVitals(5000, 0.3, 0), LevelFail, LevelOk, "SLA ✖ LCP, CLS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Tiers: []Tier{{Name: "SLA", Effect: tt.effect, Budgets: budgets}}}
+			if err := cfg.Compile(); err != nil {
+				t.Fatal(err)
+			}
+			result := NewRules(nil, cfg).Evaluate(tt.report)
+			if len(result.TierVerdicts) != 1 {
+				t.Fatalf("got %d tier verdicts, want 1", len(result.TierVerdicts))
+			}
+			v := result.TierVerdicts[0]
+			if v.Level != tt.level || v.EffectiveLevel() != tt.effective {
+				t.Errorf("tier level = %s, effective %s, want %s, effective %s", v.Level, v.EffectiveLevel(), tt.level, tt.effective)
+			}
+			if result.Level() != tt.effective {
+				t.Errorf("result level = %s, want %s", result.Level(), tt.effective)
+			}
+			if got := v.String(); got != tt.str {
+				t.Errorf("String() = %q, want %q", got, tt.str)
+			}
+		})
+	}
+}
+
+func TestTierPreset(t *testing.T) {
+	cfg := &Config{Tiers: []Tier{{Name: "target", Effect: TierEffectWarning, Preset: "google-cwv-good"}}}
+	if err := cfg.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	good, _ := Preset("google-cwv-good")
+	if len(cfg.Tiers[0].Budgets) != len(good.Budgets) {
+		t.Errorf("tier has %d budgets, want the %d of the preset", len(cfg.Tiers[0].Budgets), len(good.Budgets))
+	}
+	v := NewRules(nil, cfg).Evaluate(withVitals(2600, 0, 0)).TierVerdicts[0]
+	if v.Level != LevelFail || v.EffectiveLevel() != LevelWarn {
+		t.Errorf("tier level = %s, effective %s, want fail, effective warn", v.Level, v.EffectiveLevel())
+	}
+}
+
+func TestTierValidate(t *testing.T) {
+	id := int32(7)
+	budgets := []vfrogapi.PerformanceBudget{{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, Warning: 2501, Error: 4001}}
+	tests := []struct {
+		name string
+		tier Tier
+		want string
+	}{
+		{"no name", Tier{Effect: TierEffectError, Budgets: budgets}, "tier has no name"},
+		{"unknown effect", Tier{Name: "SLA", Effect: "fatal", Budgets: budgets}, `unknown effect "fatal"`},
+		{"preset and id", Tier{Name: "SLA", Effect: TierEffectError, Preset: "google-cwv-good", PerformanceBudgetsId: &id}, "preset and a performance budgets id"},
+		{"no budgets", Tier{Name: "SLA", Effect: TierEffectError}, "has no budgets"},
+		{"unknown preset", Tier{Name: "SLA", Effect: TierEffectError, Preset: "foo"}, `unknown preset "foo"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Tiers: []Tier{tt.tier}}
+			if err := cfg.Compile(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package budget
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"strconv"
+)
+
+// Unit is the unit of a metric value
+type Unit string
+
+const (
+	// UnitMilliseconds is used by all timing metrics
+	UnitMilliseconds Unit = "ms"
+	// UnitBytes is used by payload sizes
+	UnitBytes Unit = "B"
+	// UnitScore is used by unitless scores like the cumulative layout shift
+	UnitScore Unit = ""
+)
+
+// Value is a metric value in the unit of its metric. Fractional metrics (cumulative layout shift) are kept exact.
+type Value struct {
+	Amount float64
+	Unit   Unit
+}
+
+// String formats the value with its unit. E.g. "2500ms", "1.2MB" or "0.109"
+func (v Value) String() string {
+	switch v.Unit {
+	case UnitMilliseconds:
+		return fmt.Sprintf("%sms", strconv.FormatFloat(v.Amount, 'f', -1, 64))
+	case UnitBytes:
+		switch {
+		case v.Amount >= 1000*1000:
+			return fmt.Sprintf("%.1fMB", v.Amount/1000/1000)
+		case v.Amount >= 1000:
+			return fmt.Sprintf("%.1fkB", v.Amount/1000)
+		}
+		return fmt.Sprintf("%sB", strconv.FormatFloat(v.Amount, 'f', -1, 64))
+	}
+	return strconv.FormatFloat(v.Amount, 'f', -1, 64)
+}
+
+// MetricInfo documents a budget metric
+type MetricInfo struct {
+	Metric vfrogapi.PerformanceBudgetMetric
+	Name   string
+	// Short is the common abbreviation of the metric, e.g. "LCP"
+	Short string
+	Unit  Unit
+	// BudgetScale is the factor between a metric value and the thresholds of a vfrogapi.PerformanceBudget
+	// (threshold = value * BudgetScale). The api only allows integer thresholds, so the cumulative layout shift is
+	// budgeted in hundredths: a warning of 10 means a CLS of 0.10.
+	BudgetScale float64
+	// Computed metrics are derived from other metrics of the report by this client. The VitalFrog API does not know
+	// them, so they can only be budgeted in local budgets files
+	Computed bool
+}
+
+// MetricPerformanceScore is the Lighthouse style 0–100 performance score (see package score). Higher is better, so it
+// is budgeted with mode "below"
+const MetricPerformanceScore vfrogapi.PerformanceBudgetMetric = "performance_score"
+
+// metricInfos documents all metrics. Ordered like Metrics
+var metricInfos = []MetricInfo{
+	{Metric: vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs, Name: "First Contentful Paint", Short: "FCP", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricFirstMeaningfulPaintMs, Name: "First Meaningful Paint", Short: "FMP", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, Name: "Largest Contentful Paint", Short: "LCP", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricSpeedIndexMs, Name: "Speed Index", Short: "SI", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricInteractiveMs, Name: "Time to interactive", Short: "TTI", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs, Name: "Total Blocking Time", Short: "TBT", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricMaxPotentialFidMs, Name: "Max First Input Delay", Short: "FID", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, Name: "Cumulative Layout Shift", Short: "CLS", Unit: UnitScore, BudgetScale: 100},
+	{Metric: vfrogapi.PerformanceBudgetMetricServerResponseTimeMs, Name: "Server response time", Short: "SRT", Unit: UnitMilliseconds, BudgetScale: 1},
+	{Metric: vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes, Name: "Big payloads", Short: "BP", Unit: UnitBytes, BudgetScale: 1},
+	{Metric: MetricPerformanceScore, Name: "Performance score", Short: "Score", Unit: UnitScore, BudgetScale: 1, Computed: true},
+}
+
+// Info returns the documentation of the metric. Returns false for unknown metrics
+func Info(metric vfrogapi.PerformanceBudgetMetric) (MetricInfo, bool) {
+	for _, info := range metricInfos {
+		if info.Metric == metric {
+			return info, true
+		}
+	}
+	return MetricInfo{}, false
+}
+
+// ThresholdValue converts a budget threshold of the metric into a value in the unit of the metric
+func ThresholdValue(metric vfrogapi.PerformanceBudgetMetric, threshold int32) Value {
+	info, ok := Info(metric)
+	if !ok {
+		return Value{Amount: float64(threshold)}
+	}
+	return Value{Amount: float64(threshold) / info.BudgetScale, Unit: info.Unit}
+}
@@ -0,0 +1,84 @@
+package budget
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"testing"
+)
+
+// withVitals returns a report with the given LCP, CLS and FID, all other metrics are zero
+func withVitals(lcp int32, cls float32, fid int32) vfrogapi.PerformanceReport {
+	return vfrogapi.PerformanceReport{
+		Path:                   "/",
+		Device:                 vfrogapi.Device{Name: vfrogapi.Mobile},
+		LargestContentfulPaint: vfrogapi.LargestContentfulPaint{ValueMs: lcp},
+		CumulativeLayoutShift:  vfrogapi.CumulativeLayoutShift{Value: cls},
+		MaxPotentialFidMs:      fid,
+	}
+}
+
+func TestThresholdValue(t *testing.T) {
+	tests := []struct {
+		metric    vfrogapi.PerformanceBudgetMetric
+		threshold int32
+		want      Value
+	}{
+		{vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, 10, Value{Amount: 0.1, Unit: UnitScore}},
+		{vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, 25, Value{Amount: 0.25, Unit: UnitScore}},
+		{vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift, 70, Value{Amount: 0.7, Unit: UnitScore}},
+		{vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs, 2500, Value{Amount: 2500, Unit: UnitMilliseconds}},
+		{vfrogapi.PerformanceBudgetMetricBigPayloadsTotalBytes, 1000, Value{Amount: 1000, Unit: UnitBytes}},
+		{"unknown", 7, Value{Amount: 7}},
+	}
+	for _, tt := range tests {
+		if got := ThresholdValue(tt.metric, tt.threshold); got != tt.want {
+			t.Errorf("ThresholdValue(%s, %d) = %+v, want %+v", tt.metric, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestCompareCLSHundredths(t *testing.T) {
+	above, below := vfrogapi.Above, vfrogapi.Below
+	cls := vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift
+	tests := []struct {
+		name   string
+		value  float32
+		budget vfrogapi.PerformanceBudget
+		want   Level
+	}{
+		{"below the warning", 0.099, vfrogapi.PerformanceBudget{Metric: cls, Warning: 10, Error: 25}, LevelOk},
+		{"at the warning", 0.1, vfrogapi.PerformanceBudget{Metric: cls, Warning: 10, Error: 25}, LevelWarn},
+		{"between the hundredths", 0.109, vfrogapi.PerformanceBudget{Metric: cls, Warning: 10, Error: 25}, LevelWarn},
+		{"at the error", 0.25, vfrogapi.PerformanceBudget{Metric: cls, Warning: 10, Error: 25}, LevelFail},
+		// float32(0.7) is 0.69999998...
+		{"float32 below its decimal", 0.7, vfrogapi.PerformanceBudget{Metric: cls, Warning: 50, Error: 70, Mode: &above}, LevelFail},
+		{"mode below at the warning", 0.5, vfrogapi.PerformanceBudget{Metric: cls, Warning: 50, Error: 20, Mode: &below}, LevelWarn},
+		{"mode below above the warning", 0.51, vfrogapi.PerformanceBudget{Metric: cls, Warning: 50, Error: 20, Mode: &below}, LevelOk},
+		{"mode below at the error", 0.2, vfrogapi.PerformanceBudget{Metric: cls, Warning: 50, Error: 20, Mode: &below}, LevelFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, _ := MetricValue(withVitals(0, tt.value, 0), cls)
+			if got := Compare(value, tt.budget); got.Level != tt.want {
+				t.Errorf("Compare() = %s (%s), want %s", got.Level, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueString(t *testing.T) {
+	tests := []struct {
+		value Value
+		want  string
+	}{
+		{Value{Amount: 2500, Unit: UnitMilliseconds}, "2500ms"},
+		{Value{Amount: 0.109, Unit: UnitScore}, "0.109"},
+		{Value{Amount: 999, Unit: UnitBytes}, "999B"},
+		{Value{Amount: 1500, Unit: UnitBytes}, "1.5kB"},
+		{Value{Amount: 1200000, Unit: UnitBytes}, "1.2MB"},
+	}
+	for _, tt := range tests {
+		if got := tt.value.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
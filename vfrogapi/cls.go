@@ -0,0 +1,13 @@
+package vfrogapi
+
+import "strconv"
+
+// Exact returns the value as the float64 closest to its shortest decimal representation. The api sends the CLS as
+// float32, so 0.7 is 0.69999998... and would otherwise pass a threshold of 0.7
+func (c CumulativeLayoutShift) Exact() float64 {
+	exact, err := strconv.ParseFloat(strconv.FormatFloat(float64(c.Value), 'g', -1, 32), 64)
+	if err != nil {
+		return float64(c.Value)
+	}
+	return exact
+}
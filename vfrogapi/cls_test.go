@@ -0,0 +1,22 @@
+package vfrogapi
+
+import "testing"
+
+func TestCumulativeLayoutShiftExact(t *testing.T) {
+	tests := []struct {
+		value float32
+		want  float64
+	}{
+		{0, 0},
+		{0.1, 0.1},
+		{0.109, 0.109},
+		{0.25, 0.25},
+		{0.7, 0.7},
+		{1.5, 1.5},
+	}
+	for _, tt := range tests {
+		if got := (CumulativeLayoutShift{Value: tt.value}).Exact(); got != tt.want {
+			t.Errorf("Exact() of %v = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package score computes a Lighthouse style 0–100 performance score from the metrics of a VitalFrog performance report.
+//
+// Every metric is mapped to 0–1 by a log-normal curve defined by two control points: the value scoring 0.9 (p10) and
+// the value scoring 0.5 (median). The performance score is the weighted mean of the metric scores. Weights and curves
+// differ between Lighthouse versions, so they are versioned as Weighting sets.
+package score
+
+import (
+	"fmt"
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"math"
+	"sort"
+)
+
+// Curve are the control points of the log-normal curve of a metric, in the unit of the metric
+type Curve struct {
+	// P10 is the value scoring 0.9
+	P10 float64
+	// Median is the value scoring 0.5
+	Median float64
+}
+
+// Weighting is a versioned set of metric weights and curves per device
+type Weighting struct {
+	Version string
+	// Weights of the metrics. They do not need to sum up to 1
+	Weights map[vfrogapi.PerformanceBudgetMetric]float64
+	// Curves per device. Devices without own curves use the mobile ones
+	Curves map[vfrogapi.DeviceName]map[vfrogapi.PerformanceBudgetMetric]Curve
+}
+
+// DefaultVersion is the weighting used if none is selected
+const DefaultVersion = "v10"
+
+var (
+	// curves of Lighthouse 8 and 10
+	mobileCurves = map[vfrogapi.PerformanceBudgetMetric]Curve{
+		vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:   {P10: 1800, Median: 3000},
+		vfrogapi.PerformanceBudgetMetricSpeedIndexMs:             {P10: 3387, Median: 5800},
+		vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs: {P10: 2500, Median: 4000},
+		vfrogapi.PerformanceBudgetMetricInteractiveMs:            {P10: 3785, Median: 7300},
+		vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:      {P10: 200, Median: 600},
+		vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:    {P10: 0.1, Median: 0.25},
+	}
+	desktopCurves = map[vfrogapi.PerformanceBudgetMetric]Curve{
+		vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:   {P10: 934, Median: 1600},
+		vfrogapi.PerformanceBudgetMetricSpeedIndexMs:             {P10: 1311, Median: 2300},
+		vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs: {P10: 1200, Median: 2400},
+		vfrogapi.PerformanceBudgetMetricInteractiveMs:            {P10: 2468, Median: 4500},
+		vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:      {P10: 150, Median: 350},
+		vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:    {P10: 0.1, Median: 0.25},
+	}
+	// curves of Lighthouse 6, which differ for mobile FCP and TBT
+	mobileCurvesV6 = withCurves(mobileCurves, map[vfrogapi.PerformanceBudgetMetric]Curve{
+		vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs: {P10: 2336, Median: 4000},
+		vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:    {P10: 287, Median: 600},
+	})
+)
+
+// weightings are all known weighting sets by version
+var weightings = map[string]Weighting{
+	"v10": {
+		Version: "v10",
+		Weights: map[vfrogapi.PerformanceBudgetMetric]float64{
+			vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:   0.10,
+			vfrogapi.PerformanceBudgetMetricSpeedIndexMs:             0.10,
+			vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs: 0.25,
+			vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:      0.30,
+			vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:    0.25,
+		},
+		Curves: map[vfrogapi.DeviceName]map[vfrogapi.PerformanceBudgetMetric]Curve{vfrogapi.Mobile: mobileCurves, vfrogapi.Desktop: desktopCurves},
+	},
+	"v8": {
+		Version: "v8",
+		Weights: map[vfrogapi.PerformanceBudgetMetric]float64{
+			vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:   0.10,
+			vfrogapi.PerformanceBudgetMetricSpeedIndexMs:             0.10,
+			vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs: 0.25,
+			vfrogapi.PerformanceBudgetMetricInteractiveMs:            0.10,
+			vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:      0.30,
+			vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:    0.15,
+		},
+		Curves: map[vfrogapi.DeviceName]map[vfrogapi.PerformanceBudgetMetric]Curve{vfrogapi.Mobile: mobileCurves, vfrogapi.Desktop: desktopCurves},
+	},
+	"v6": {
+		Version: "v6",
+		Weights: map[vfrogapi.PerformanceBudgetMetric]float64{
+			vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:   0.15,
+			vfrogapi.PerformanceBudgetMetricSpeedIndexMs:             0.15,
+			vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs: 0.25,
+			vfrogapi.PerformanceBudgetMetricInteractiveMs:            0.15,
+			vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:      0.25,
+			vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:    0.05,
+		},
+		Curves: map[vfrogapi.DeviceName]map[vfrogapi.PerformanceBudgetMetric]Curve{vfrogapi.Mobile: mobileCurvesV6, vfrogapi.Desktop: desktopCurves},
+	},
+}
+
+func withCurves(base, replaced map[vfrogapi.PerformanceBudgetMetric]Curve) map[vfrogapi.PerformanceBudgetMetric]Curve {
+	curves := map[vfrogapi.PerformanceBudgetMetric]Curve{}
+	for metric, curve := range base {
+		curves[metric] = curve
+	}
+	for metric, curve := range replaced {
+		curves[metric] = curve
+	}
+	return curves
+}
+
+// Lookup returns the weighting set of the version
+func Lookup(version string) (Weighting, error) {
+	w, ok := weightings[version]
+	if !ok {
+		return Weighting{}, fmt.Errorf("unknown score weighting %q. Use one of %v", version, Versions())
+	}
+	return w, nil
+}
+
+// Default returns the weighting set of DefaultVersion
+func Default() Weighting {
+	return weightings[DefaultVersion]
+}
+
+// Versions returns all known weighting versions
+func Versions() []string {
+	versions := make([]string, 0, len(weightings))
+	for v := range weightings {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Score returns the performance score of the report from 0 to 100, rounded to an integer like Lighthouse does. Like
+// Lighthouse, the metric scores are rounded to two decimals before they are weighted
+func (w Weighting) Score(report vfrogapi.PerformanceReport) float64 {
+	curves, ok := w.Curves[report.Device.Name]
+	if !ok {
+		curves = w.Curves[vfrogapi.Mobile]
+	}
+	var sum, weights float64
+	for metric, weight := range w.Weights {
+		curve, ok := curves[metric]
+		if !ok {
+			continue
+		}
+		sum += weight * math.Round(logNormalScore(curve, metricValue(report, metric))*100) / 100
+		weights += weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return math.Round(sum / weights * 100)
+}
+
+// PathScores returns the mean score of all reports (devices, countries) per path
+func (w Weighting) PathScores(reports []vfrogapi.PerformanceReport) map[string]float64 {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for _, report := range reports {
+		sums[report.Path] += w.Score(report)
+		counts[report.Path]++
+	}
+	scores := map[string]float64{}
+	for path, sum := range sums {
+		scores[path] = math.Round(sum / float64(counts[path]))
+	}
+	return scores
+}
+
+// inverseErfcOneFifth is erfc⁻¹(0.2). It places the p10 control point at a score of 0.9
+const inverseErfcOneFifth = 0.9061938024368232
+
+// logNormalScore maps the value to 0–1 by the complementary log-normal distribution through the control points.
+// Like Lighthouse, scores are clamped to their band: values up to p10 score at least 0.9, values up to the median at
+// least 0.5.
+func logNormalScore(c Curve, value float64) float64 {
+	if value <= 0 {
+		return 1
+	}
+	standardized := math.Log(value/c.Median) * inverseErfcOneFifth / -math.Log(c.P10/c.Median)
+	score := math.Erfc(standardized) / 2
+	switch {
+	case value <= c.P10:
+		return math.Max(0.9, math.Min(1, score))
+	case value <= c.Median:
+		return math.Max(0.5, math.Min(0.8999999999999999, score))
+	}
+	return math.Max(0, math.Min(0.49999999999999994, score))
+}
+
+func metricValue(report vfrogapi.PerformanceReport, metric vfrogapi.PerformanceBudgetMetric) float64 {
+	switch metric {
+	case vfrogapi.PerformanceBudgetMetricFirstContentfulPaintMs:
+		return float64(report.FirstContentfulPaint.ValueMs)
+	case vfrogapi.PerformanceBudgetMetricSpeedIndexMs:
+		return float64(report.SpeedIndexMs)
+	case vfrogapi.PerformanceBudgetMetricLargestContentfulPaintMs:
+		return float64(report.LargestContentfulPaint.ValueMs)
+	case vfrogapi.PerformanceBudgetMetricInteractiveMs:
+		return float64(report.InteractiveMs)
+	case vfrogapi.PerformanceBudgetMetricTotalBlockingTimeMs:
+		return float64(report.TotalBlockingTimeMs)
+	case vfrogapi.PerformanceBudgetMetricCumulativeLayoutShift:
+		return report.CumulativeLayoutShift.Exact()
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package score
+
+import (
+	"github.com/VitalFrog/vitalfrog-go-client/vfrogapi"
+	"math"
+	"testing"
+)
+
+func report(device vfrogapi.DeviceName, fcp, si, lcp, tbt int32, cls float32) vfrogapi.PerformanceReport {
+	return vfrogapi.PerformanceReport{
+		Device:                 vfrogapi.Device{Name: device},
+		FirstContentfulPaint:   vfrogapi.FirstContentfulPaint{ValueMs: fcp},
+		SpeedIndexMs:           si,
+		LargestContentfulPaint: vfrogapi.LargestContentfulPaint{ValueMs: lcp},
+		TotalBlockingTimeMs:    tbt,
+		CumulativeLayoutShift:  vfrogapi.CumulativeLayoutShift{Value: cls},
+	}
+}
+
+func TestInverseErfcOneFifth(t *testing.T) {
+	if got := math.Erfc(inverseErfcOneFifth); math.Abs(got-0.2) > 1e-15 {
+		t.Errorf("erfc(inverseErfcOneFifth) = %v, want 0.2", got)
+	}
+}
+
+// The scores are computed like Lighthouse v10 does (log-normal metric scores rounded to two decimals, weighted mean).
+// The "rounded" rows round to a different integer with an inexact erfc⁻¹(0.2) or unrounded metric scores
+func TestWeightingScoreV10(t *testing.T) {
+	tests := []struct {
+		name   string
+		report vfrogapi.PerformanceReport
+		want   float64
+	}{
+		{"mobile at p10", report(vfrogapi.Mobile, 1800, 3387, 2500, 200, 0.1), 90},
+		{"mobile at median", report(vfrogapi.Mobile, 3000, 5800, 4000, 600, 0.25), 50},
+		{"mobile good", report(vfrogapi.Mobile, 1200, 2800, 3100, 350, 0.05), 85},
+		{"mobile poor", report(vfrogapi.Mobile, 2600, 4900, 5200, 900, 0.18), 46},
+		{"mobile rounded metric scores", report(vfrogapi.Mobile, 4087, 4861, 5329, 477, 0.138), 53},
+		{"desktop good", report(vfrogapi.Desktop, 700, 1100, 1500, 120, 0.02), 93},
+		{"desktop poor", report(vfrogapi.Desktop, 1400, 2100, 3000, 400, 0.3), 43},
+		{"desktop rounded metric scores", report(vfrogapi.Desktop, 640, 3465, 5814, 823, 0.36), 24},
+		{"zero values", report(vfrogapi.Mobile, 0, 0, 0, 0, 0), 100},
+		{"unknown device uses mobile curves", report("tablet", 1800, 3387, 2500, 200, 0.1), 90},
+	}
+	w, err := Lookup("v10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.Score(tt.report); got != tt.want {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogNormalScoreBands(t *testing.T) {
+	curve := Curve{P10: 200, Median: 600}
+	tests := []struct {
+		value    float64
+		min, max float64
+	}{
+		{0, 1, 1},
+		{200, 0.9, 0.9},
+		{201, 0.5, 0.9},
+		{600, 0.5, 0.5},
+		{601, 0, 0.5},
+		{100000, 0, 0.01},
+	}
+	for _, tt := range tests {
+		if got := logNormalScore(curve, tt.value); got < tt.min-1e-9 || got > tt.max+1e-9 {
+			t.Errorf("logNormalScore(%v) = %v, want within [%v, %v]", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
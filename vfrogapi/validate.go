@@ -0,0 +1,127 @@
+package vfrogapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PerformanceBudgetMetrics are all metrics the api knows
+var PerformanceBudgetMetrics = []PerformanceBudgetMetric{
+	PerformanceBudgetMetricBigPayloadsTotalBytes,
+	PerformanceBudgetMetricCumulativeLayoutShift,
+	PerformanceBudgetMetricFirstContentfulPaintMs,
+	PerformanceBudgetMetricFirstMeaningfulPaintMs,
+	PerformanceBudgetMetricInteractiveMs,
+	PerformanceBudgetMetricLargestContentfulPaintMs,
+	PerformanceBudgetMetricMaxPotentialFidMs,
+	PerformanceBudgetMetricServerResponseTimeMs,
+	PerformanceBudgetMetricSpeedIndexMs,
+	PerformanceBudgetMetricTotalBlockingTimeMs,
+}
+
+// Valid reports whether the metric is one of PerformanceBudgetMetrics
+func (m PerformanceBudgetMetric) Valid() bool {
+	for _, known := range PerformanceBudgetMetrics {
+		if m == known {
+			return true
+		}
+	}
+	return false
+}
+
+// BudgetProblemKind classifies a BudgetProblem
+type BudgetProblemKind string
+
+const (
+	BudgetProblemUnknownMetric     BudgetProblemKind = "unknown_metric"
+	BudgetProblemUnknownMode       BudgetProblemKind = "unknown_mode"
+	BudgetProblemNegativeThreshold BudgetProblemKind = "negative_threshold"
+	BudgetProblemThresholdOrder    BudgetProblemKind = "threshold_order"
+	BudgetProblemDuplicateMetric   BudgetProblemKind = "duplicate_metric"
+)
+
+// BudgetProblem is a single inconsistency of a performance budget
+type BudgetProblem struct {
+	// Index of the budget in PerformanceBudgets.Budgets
+	Index   int
+	Metric  PerformanceBudgetMetric
+	Kind    BudgetProblemKind
+	Message string
+}
+
+func (p BudgetProblem) String() string {
+	return fmt.Sprintf("budget %d (%s): %s", p.Index, p.Metric, p.Message)
+}
+
+// BudgetProblems are all inconsistencies of performance budgets. Usable as error
+type BudgetProblems []BudgetProblem
+
+func (p BudgetProblems) Error() string {
+	problems := make([]string, 0, len(p))
+	for _, problem := range p {
+		problems = append(problems, problem.String())
+	}
+	return strings.Join(problems, "; ")
+}
+
+// Validate checks the budget for internal consistency: a known metric and mode, no negative thresholds and the
+// warning threshold being crossed before the error threshold. Equal thresholds are fine, they fail right away.
+// Returns nil if the budget makes sense.
+func (b PerformanceBudget) Validate() BudgetProblems {
+	var problems BudgetProblems
+	add := func(kind BudgetProblemKind, format string, a ...interface{}) {
+		problems = append(problems, BudgetProblem{Metric: b.Metric, Kind: kind, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if !b.Metric.Valid() {
+		add(BudgetProblemUnknownMetric, "unknown metric %q", b.Metric)
+	}
+	if b.Warning < 0 {
+		add(BudgetProblemNegativeThreshold, "negative warning threshold %d", b.Warning)
+	}
+	if b.Error < 0 {
+		add(BudgetProblemNegativeThreshold, "negative error threshold %d", b.Error)
+	}
+
+	mode := Above
+	if b.Mode != nil {
+		mode = *b.Mode
+	}
+	switch mode {
+	case Above:
+		if b.Warning > b.Error {
+			add(BudgetProblemThresholdOrder, "warning threshold %d is above the error threshold %d for mode %q, so it never warns", b.Warning, b.Error, mode)
+		}
+	case Below:
+		if b.Warning < b.Error {
+			add(BudgetProblemThresholdOrder, "warning threshold %d is below the error threshold %d for mode %q, so it never warns", b.Warning, b.Error, mode)
+		}
+	default:
+		add(BudgetProblemUnknownMode, "unknown mode %q. Use %q or %q", mode, Above, Below)
+	}
+	return problems
+}
+
+// Validate checks every budget (see PerformanceBudget.Validate) and that no metric is listed twice.
+// Returns nil if the budgets make sense.
+func (b PerformanceBudgets) Validate() BudgetProblems {
+	var problems BudgetProblems
+	first := map[PerformanceBudgetMetric]int{}
+	for k, budget := range b.Budgets {
+		for _, problem := range budget.Validate() {
+			problem.Index = k
+			problems = append(problems, problem)
+		}
+		if i, ok := first[budget.Metric]; ok {
+			problems = append(problems, BudgetProblem{
+				Index:   k,
+				Metric:  budget.Metric,
+				Kind:    BudgetProblemDuplicateMetric,
+				Message: fmt.Sprintf("metric is already budgeted by budget %d, both are evaluated", i),
+			})
+			continue
+		}
+		first[budget.Metric] = k
+	}
+	return problems
+}
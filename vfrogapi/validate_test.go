@@ -0,0 +1,64 @@
+package vfrogapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPerformanceBudgetsValidate(t *testing.T) {
+	above, below, sideways := Above, Below, PerformanceBudgetMode("sideways")
+	lcp := PerformanceBudgetMetricLargestContentfulPaintMs
+	type problem struct {
+		index int
+		kind  BudgetProblemKind
+	}
+	tests := []struct {
+		name    string
+		budgets []PerformanceBudget
+		want    []problem
+	}{
+		{"valid", []PerformanceBudget{{Metric: lcp, Warning: 2501, Error: 4001}}, nil},
+		{"equal thresholds", []PerformanceBudget{{Metric: lcp, Warning: 2501, Error: 2501}}, nil},
+		{"valid below", []PerformanceBudget{{Metric: lcp, Warning: 90, Error: 50, Mode: &below}}, nil},
+		{"unknown metric", []PerformanceBudget{{Metric: "lcp", Warning: 1, Error: 2}}, []problem{{0, BudgetProblemUnknownMetric}}},
+		{"negative thresholds", []PerformanceBudget{{Metric: lcp, Warning: -2, Error: -1}}, []problem{{0, BudgetProblemNegativeThreshold}, {0, BudgetProblemNegativeThreshold}}},
+		{"warning above error", []PerformanceBudget{{Metric: lcp, Warning: 4001, Error: 2501, Mode: &above}}, []problem{{0, BudgetProblemThresholdOrder}}},
+		{"warning below error in mode below", []PerformanceBudget{{Metric: lcp, Warning: 50, Error: 90, Mode: &below}}, []problem{{0, BudgetProblemThresholdOrder}}},
+		{"unknown mode", []PerformanceBudget{{Metric: lcp, Warning: 1, Error: 2, Mode: &sideways}}, []problem{{0, BudgetProblemUnknownMode}}},
+		{"duplicate metric", []PerformanceBudget{
+			{Metric: lcp, Warning: 2501, Error: 4001},
+			{Metric: PerformanceBudgetMetricCumulativeLayoutShift, Warning: 11, Error: 26},
+			{Metric: lcp, Warning: 3001, Error: 4001},
+		}, []problem{{2, BudgetProblemDuplicateMetric}}},
+		{"problems keep their index", []PerformanceBudget{
+			{Metric: lcp, Warning: 2501, Error: 4001},
+			{Metric: PerformanceBudgetMetricCumulativeLayoutShift, Warning: 26, Error: 11},
+		}, []problem{{1, BudgetProblemThresholdOrder}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []problem
+			for _, p := range (PerformanceBudgets{Budgets: tt.budgets}).Validate() {
+				got = append(got, problem{p.Index, p.Kind})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetProblemsError(t *testing.T) {
+	problems := PerformanceBudgets{Budgets: []PerformanceBudget{
+		{Metric: PerformanceBudgetMetricLargestContentfulPaintMs, Warning: 4001, Error: 2501},
+		{Metric: "foo", Warning: 1, Error: 1},
+	}}.Validate()
+	want := `budget 0 (largest_contentful_paint_ms): warning threshold 4001 is above the error threshold 2501 for mode "above", so it never warns; budget 1 (foo): unknown metric "foo"`
+	if got := problems.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !strings.Contains(problems[0].String(), "budget 0") {
+		t.Errorf("String() = %q, want the index", problems[0].String())
+	}
+}
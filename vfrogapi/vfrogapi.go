@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 )
 
 // Client is a http client to access the VitalFrog api with a few convenience functions
@@ -34,6 +36,16 @@ func (c Client) GetPerformanceBudgets(performanceBudgetsId int32) (*PerformanceB
 	return budgets, nil
 }
 
+// ListPerformanceBudgets GETs all performance budgets of the account
+func (c Client) ListPerformanceBudgets() (PerformanceBudgetsList, error) {
+	budgets := PerformanceBudgetsList{}
+	err := c.getJSON("/performance_budgets", &budgets)
+	if err != nil {
+		return nil, fmt.Errorf("could not getJSON: %w", err)
+	}
+	return budgets, nil
+}
+
 // CreateReport starts a new report via the VitalFrog api. Not returning any performance reports
 func (c Client) CreateReport(config ReportConfig) (*ReportMetadata, error) {
 	metadata := &ReportMetadata{}
@@ -44,6 +56,34 @@ func (c Client) CreateReport(config ReportConfig) (*ReportMetadata, error) {
 	return metadata, nil
 }
 
+// ListReports GETs the metadata of all reports of the component on the version, without performance reports. The
+// reports are fetched page by page until an empty page is returned
+func (c Client) ListReports(component, version string) ([]ReportMetadata, error) {
+	query := url.Values{"component": {component}, "version": {version}}
+	reports := make([]ReportMetadata, 0)
+	seen := map[string]bool{}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		pageReports := make([]ReportMetadata, 0)
+		err := c.getJSON("/reports?"+query.Encode(), &pageReports)
+		if err != nil {
+			return nil, fmt.Errorf("could not getJSON page %d: %w", page, err)
+		}
+		added := 0
+		for _, r := range pageReports {
+			if !seen[r.Uuid] {
+				seen[r.Uuid] = true
+				reports = append(reports, r)
+				added++
+			}
+		}
+		// a page without new reports is the end, even if the server ignores the page and repeats the first one
+		if added == 0 {
+			return reports, nil
+		}
+	}
+}
+
 // GetReport gets the report data by uuid
 func (c Client) GetReport(uuid string) (*Report, error) {
 	report := &Report{}
@@ -0,0 +1,61 @@
+package vfrogapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestListReportsPages(t *testing.T) {
+	tests := []struct {
+		name       string
+		pages      [][]string
+		ignorePage bool
+		want       int
+		requests   int
+	}{
+		{"single page", [][]string{{"a", "b"}}, false, 2, 2},
+		{"several pages", [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, false, 5, 4},
+		{"no reports", nil, false, 0, 1},
+		{"server ignores the page", [][]string{{"a", "b"}}, true, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if got := r.URL.Query().Get("component"); got != "shop" {
+					t.Errorf("component = %q, want shop", got)
+				}
+				if got := r.URL.Query().Get("version"); got != "1.0" {
+					t.Errorf("version = %q, want 1.0", got)
+				}
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if tt.ignorePage {
+					page = 1
+				}
+				reports := make([]ReportMetadata, 0)
+				if page >= 1 && page <= len(tt.pages) {
+					for _, uuid := range tt.pages[page-1] {
+						reports = append(reports, ReportMetadata{Uuid: uuid})
+					}
+				}
+				_ = json.NewEncoder(w).Encode(reports)
+			}))
+			defer server.Close()
+
+			reports, err := New(server.URL, "token").ListReports("shop", "1.0")
+			if err != nil {
+				t.Fatalf("ListReports() error = %v", err)
+			}
+			if len(reports) != tt.want {
+				t.Errorf("ListReports() returned %d reports, want %d", len(reports), tt.want)
+			}
+			if requests != tt.requests {
+				t.Errorf("ListReports() made %d requests, want %d", requests, tt.requests)
+			}
+		})
+	}
+}